@@ -0,0 +1,167 @@
+package main
+
+import (
+	"github.com/test-network-function/pgt2acm/packages/acmformat"
+	"gopkg.in/yaml.v3"
+)
+
+// Returns the YAML node of a converted template with the comments of the PGT source files: the head
+// comment of a source file goes to the manifest converted from it, the line comment of its file name
+// to the manifest path, and the comments of the overridden fields to the same fields of the patches
+func templateNodeWithComments(pgtDocument *yaml.Node, acmGenTempConversion *acmformat.AcmGenTemplate) (*yaml.Node, error) {
+	templateNode := &yaml.Node{}
+	err := templateNode.Encode(acmGenTempConversion)
+	if err != nil {
+		return nil, err
+	}
+	if pgtDocument == nil {
+		return templateNode, nil
+	}
+	pgtRoot := pgtDocument
+	if pgtRoot.Kind == yaml.DocumentNode && len(pgtRoot.Content) != 0 {
+		pgtRoot = pgtRoot.Content[0]
+	}
+	sourceFilesNode := mappingValue(mappingValue(pgtRoot, "spec"), "sourceFiles")
+	if sourceFilesNode == nil || sourceFilesNode.Kind != yaml.SequenceNode {
+		return templateNode, nil
+	}
+
+	policiesNode := mappingValue(templateNode, "policies")
+	for policyIndex := range acmGenTempConversion.Policies {
+		if policiesNode == nil || policyIndex >= len(policiesNode.Content) {
+			break
+		}
+		manifestsNode := mappingValue(policiesNode.Content[policyIndex], "manifests")
+		for manifestIndex, manifest := range acmGenTempConversion.Policies[policyIndex].Manifests {
+			if manifestsNode == nil || manifestIndex >= len(manifestsNode.Content) || manifest.SourceFileIndex >= len(sourceFilesNode.Content) {
+				break
+			}
+			copySourceFileComments(sourceFilesNode.Content[manifest.SourceFileIndex], manifestsNode.Content[manifestIndex])
+		}
+	}
+	return templateNode, nil
+}
+
+// Copies the comments of a PGT source file node to the node of the manifest converted from it
+func copySourceFileComments(sourceFileNode, manifestNode *yaml.Node) {
+	manifestNode.HeadComment = sourceFileNode.HeadComment
+	if fileNameNode := mappingValue(sourceFileNode, "fileName"); fileNameNode != nil {
+		if pathNode := mappingValue(manifestNode, "path"); pathNode != nil {
+			pathNode.LineComment = fileNameNode.LineComment
+		}
+	}
+	patchesNode := mappingValue(manifestNode, "patches")
+	if patchesNode == nil {
+		return
+	}
+	for _, patchNode := range patchesNode.Content {
+		copyFieldComments(sourceFileNode, patchNode)
+		// The identity of a patch comes first, as in a manifest
+		orderMappingKeys(patchNode, []string{"apiVersion", "kind"})
+	}
+}
+
+// Copies the head and line comments of the fields of a PGT node to the same fields of a patch node, and
+// orders the fields as in the PGT, the fields not overridden by the PGT coming last. List items are
+// matched by name when they have one, since pre-rendering may reorder them, otherwise by index
+func copyFieldComments(pgtNode, patchNode *yaml.Node) {
+	switch {
+	case pgtNode.Kind == yaml.MappingNode && patchNode.Kind == yaml.MappingNode:
+		orderMappingKeys(patchNode, mappingKeys(pgtNode))
+		for index := 0; index+1 < len(patchNode.Content); index += 2 {
+			keyNode, valueNode := patchNode.Content[index], patchNode.Content[index+1]
+			pgtKeyNode, pgtValueNode := mappingEntry(pgtNode, keyNode.Value)
+			if pgtKeyNode == nil {
+				continue
+			}
+			keyNode.HeadComment = pgtKeyNode.HeadComment
+			keyNode.LineComment = pgtKeyNode.LineComment
+			valueNode.LineComment = pgtValueNode.LineComment
+			copyFieldComments(pgtValueNode, valueNode)
+		}
+	case pgtNode.Kind == yaml.SequenceNode && patchNode.Kind == yaml.SequenceNode:
+		for index, itemNode := range patchNode.Content {
+			pgtItemNode := matchingItem(pgtNode, itemNode, index)
+			if pgtItemNode == nil {
+				continue
+			}
+			itemNode.HeadComment = pgtItemNode.HeadComment
+			itemNode.LineComment = pgtItemNode.LineComment
+			copyFieldComments(pgtItemNode, itemNode)
+			// The head comment of the first field of a list item is written before the item
+			if itemNode.Kind == yaml.MappingNode && len(itemNode.Content) != 0 && itemNode.Content[0].HeadComment != "" {
+				itemNode.HeadComment = joinComments(itemNode.HeadComment, itemNode.Content[0].HeadComment)
+				itemNode.Content[0].HeadComment = ""
+			}
+		}
+	}
+}
+
+// Orders the fields of a mapping node: the fields with the first keys come first, in the order of the
+// keys, and the other fields keep their order
+func orderMappingKeys(node *yaml.Node, firstKeys []string) {
+	var ordered, others []*yaml.Node
+	isFirstKey := map[string]bool{}
+	for _, key := range firstKeys {
+		isFirstKey[key] = true
+		if keyNode, valueNode := mappingEntry(node, key); keyNode != nil {
+			ordered = append(ordered, keyNode, valueNode)
+		}
+	}
+	for index := 0; index+1 < len(node.Content); index += 2 {
+		if !isFirstKey[node.Content[index].Value] {
+			others = append(others, node.Content[index], node.Content[index+1])
+		}
+	}
+	node.Content = append(ordered, others...)
+}
+
+// Returns the keys of a mapping node in order
+func mappingKeys(node *yaml.Node) (keys []string) {
+	for index := 0; index+1 < len(node.Content); index += 2 {
+		keys = append(keys, node.Content[index].Value)
+	}
+	return keys
+}
+
+func joinComments(first, second string) string {
+	if first == "" {
+		return second
+	}
+	return first + "\n" + second
+}
+
+// Returns the item of a PGT list matching a patch list item
+func matchingItem(pgtNode, itemNode *yaml.Node, index int) *yaml.Node {
+	if nameNode := mappingValue(itemNode, "name"); nameNode != nil {
+		for _, pgtItemNode := range pgtNode.Content {
+			if pgtNameNode := mappingValue(pgtItemNode, "name"); pgtNameNode != nil && pgtNameNode.Value == nameNode.Value {
+				return pgtItemNode
+			}
+		}
+		return nil
+	}
+	if index < len(pgtNode.Content) {
+		return pgtNode.Content[index]
+	}
+	return nil
+}
+
+// Returns the value node of a key in a mapping node, nil if not found
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	_, valueNode := mappingEntry(node, key)
+	return valueNode
+}
+
+// Returns the key and value nodes of a key in a mapping node, nil if not found
+func mappingEntry(node *yaml.Node, key string) (keyNode, valueNode *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for index := 0; index+1 < len(node.Content); index += 2 {
+		if node.Content[index].Value == key {
+			return node.Content[index], node.Content[index+1]
+		}
+	}
+	return nil, nil
+}
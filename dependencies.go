@@ -0,0 +1,115 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/test-network-function/pgt2acm/packages/acmformat"
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"github.com/test-network-function/pgt2acm/packages/report"
+)
+
+const compliantDependency = "Compliant"
+
+// A converted policy, its position in the PGT input file and the PGT selecting its clusters
+type wavePolicy struct {
+	position      report.Position
+	policy        *acmformat.PolicyConfig
+	policyGenTemp *pgtformat.PolicyGenTemplate
+}
+
+// Makes each policy depend on the compliance of the policies of the previous ztp-deploy-wave in the
+// same namespace whose binding rules select all its clusters, so that ACM applies the policies in wave
+// order without TALM. A dependency on a policy that is not placed on the same clusters would never be
+// satisfied, so it is skipped with a warning. Policies without a wave are not ordered
+func addWaveDependencies(options *conversionOptions, templates []convertedTemplate) {
+	// policies indexed by namespace and then by wave
+	policiesByNamespace := map[string]map[int][]wavePolicy{}
+	for templateIndex := range templates {
+		template := templates[templateIndex].template
+		namespace := template.PolicyDefaults.Namespace
+		for policyIndex := range template.Policies {
+			policy := &template.Policies[policyIndex]
+			position := policyPosition(options, templates[templateIndex].inputFile, templates[templateIndex].policyGenTemp, policy)
+			wave, found := parseWave(policy.PolicyAnnotations[waveAnnotationKey])
+			if !found {
+				options.report.InfofAt(position, "policy %s: no ztp-deploy-wave, no dependency added", policy.Name)
+				continue
+			}
+			if policiesByNamespace[namespace] == nil {
+				policiesByNamespace[namespace] = map[int][]wavePolicy{}
+			}
+			policiesByNamespace[namespace][wave] = append(policiesByNamespace[namespace][wave],
+				wavePolicy{position: position, policy: policy, policyGenTemp: templates[templateIndex].policyGenTemp})
+		}
+	}
+
+	var namespaces []string
+	for namespace := range policiesByNamespace {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		policiesByWave := policiesByNamespace[namespace]
+		var waves []int
+		for wave := range policiesByWave {
+			waves = append(waves, wave)
+		}
+		sort.Ints(waves)
+		for waveIndex := 1; waveIndex < len(waves); waveIndex++ {
+			for _, dependent := range policiesByWave[waves[waveIndex]] {
+				addPolicyDependencies(options, dependent, waves[waveIndex], waves[:waveIndex], policiesByWave)
+			}
+		}
+	}
+}
+
+// Makes a policy depend on the policies of the closest previous wave selecting all its clusters, and
+// warns about the policies of the previous wave placed on other clusters
+func addPolicyDependencies(options *conversionOptions, dependent wavePolicy, wave int, previousWaves []int, policiesByWave map[int][]wavePolicy) {
+	for _, previousWavePolicy := range policiesByWave[previousWaves[len(previousWaves)-1]] {
+		if !selectsClusters(previousWavePolicy.policyGenTemp, dependent.policyGenTemp) {
+			options.report.WarnfAt(dependent.position, "policy %s (wave %d): no dependency on the wave %d policy %s, its binding rules do not select all the clusters of the policy",
+				dependent.policy.Name, wave, previousWaves[len(previousWaves)-1], previousWavePolicy.policy.Name)
+		}
+	}
+	for waveIndex := len(previousWaves) - 1; waveIndex >= 0; waveIndex-- {
+		var dependencyNames []string
+		for _, previousWavePolicy := range policiesByWave[previousWaves[waveIndex]] {
+			if selectsClusters(previousWavePolicy.policyGenTemp, dependent.policyGenTemp) {
+				dependencyNames = append(dependencyNames, previousWavePolicy.policy.Name)
+			}
+		}
+		if len(dependencyNames) == 0 {
+			continue
+		}
+		sort.Strings(dependencyNames)
+		for _, dependencyName := range dependencyNames {
+			dependent.policy.Dependencies = append(dependent.policy.Dependencies,
+				acmformat.PolicyDependency{Name: dependencyName, Compliance: compliantDependency})
+		}
+		options.report.InfofAt(dependent.position, "policy %s (wave %d): depends on the wave %d policies %s",
+			dependent.policy.Name, wave, previousWaves[waveIndex], strings.Join(dependencyNames, ", "))
+		return
+	}
+}
+
+// Returns true if the binding rules of a PGT select all the clusters selected by the binding rules of
+// another PGT: every rule of the first PGT is also required by the other one, and every cluster excluded
+// by the first PGT is also excluded by the other one. The policies of the same PGT select the same clusters
+func selectsClusters(policyGenTemp, otherPolicyGenTemp *pgtformat.PolicyGenTemplate) bool {
+	if policyGenTemp == otherPolicyGenTemp {
+		return true
+	}
+	for key, value := range policyGenTemp.Spec.BindingRules {
+		if otherValue, ok := otherPolicyGenTemp.Spec.BindingRules[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+	for key, value := range policyGenTemp.Spec.BindingExcludedRules {
+		if otherValue, ok := otherPolicyGenTemp.Spec.BindingExcludedRules[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
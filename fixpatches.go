@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/test-network-function/pgt2acm/packages/patches"
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+)
+
+const (
+	fixFlag = "fix"
+	// Rewrites the metadata of the patches matching no resource to the closest resource of the source CR
+	fixPatch = "patch"
+	// Renames the closest resource of the source CR to the name and namespace of the patch, in a copy
+	// of the source CR specific to the policy
+	fixSourceCR = "source-cr"
+)
+
+// The fields of the patches fixed to identify the closest resource
+var fixedPatchFields = [][]string{{"apiVersion"}, {"metadata", "name"}, {"metadata", "namespace"}}
+
+// The fields of the closest resource fixed to match the patch
+var fixedSourceCRFields = [][]string{{"metadata", "name"}, {"metadata", "namespace"}}
+
+// Applies the patches of a source file to its source CR. When Kustomize fails because a patch matches
+// no resource, the closest resource of the same kind in the source CR is suggested and, with the fix
+// option, the patch or the closest resource is rewritten and the patches applied again. The source CR
+// file is shared by the policies and left unchanged: the fixed source CR is written to sourceCRCopyPath
+// when not empty, and copied is then true
+func applySourceFilePatches(options *conversionOptions, inputFile string, sourceFile *pgtformat.SourceFile, patcher *patches.ManifestPatcher,
+	sourceCRCopyPath string) (patchedManifests []map[string]interface{}, copied bool, err error) {
+	patchedManifests, err = patcher.ApplyPatches(options.schema)
+	if err == nil {
+		return patchedManifests, false, nil
+	}
+	unmatched := patcher.UnmatchedPatches()
+	if len(unmatched) == 0 {
+		return nil, false, err
+	}
+
+	fixed := options.fix != ""
+	for _, unmatchedPatch := range unmatched {
+		patch := patcher.Patches[unmatchedPatch.PatchIndex]
+		position := sourceFilePosition(options, inputFile, sourceFile, "metadata.name")
+		if unmatchedPatch.ClosestIndex == -1 {
+			options.report.WarnfAt(position, "source file %s: the patch %s matches no resource, the source CR has no %s resource",
+				sourceFile.FileName, patchIdentity(patch), patches.StringField(patch, "kind"))
+			fixed = false
+			continue
+		}
+		closest := patcher.Manifests[unmatchedPatch.ClosestIndex]
+		target := patchIdentity(patch)
+		switch options.fix {
+		case fixPatch:
+			differences := copyFields(closest, patch, fixedPatchFields)
+			options.report.InfofAt(position, "source file %s: the patch %s matches no resource, its metadata is fixed to patch %s: %s",
+				sourceFile.FileName, target, patchIdentity(closest), strings.Join(differences, ", "))
+		case fixSourceCR:
+			closestIdentity := patchIdentity(closest)
+			differences := copyFields(patch, closest, fixedSourceCRFields)
+			options.report.InfofAt(position, "source file %s: the patch %s matches no resource, the closest resource %s is renamed: %s",
+				sourceFile.FileName, target, closestIdentity, strings.Join(differences, ", "))
+		default:
+			options.report.WarnfAt(position, "source file %s: the patch %s matches no resource, the closest resource of %s is %s, rerun with -%s %s or -%s %s to fix it",
+				sourceFile.FileName, target, sourceFile.FileName, patchIdentity(closest), fixFlag, fixPatch, fixFlag, fixSourceCR)
+		}
+	}
+	if !fixed {
+		return nil, false, err
+	}
+	if options.fix == fixSourceCR && sourceCRCopyPath != "" {
+		err = writeManifestsToFile(patcher.Manifests, sourceCRCopyPath)
+		if err != nil {
+			return nil, false, err
+		}
+		options.report.InfofAt(sourceFilePosition(options, inputFile, sourceFile, ""), "source file %s: the fixed source CR is written to %s", sourceFile.FileName, sourceCRCopyPath)
+		copied = true
+	}
+	patchedManifests, err = patcher.ApplyPatches(options.schema)
+	return patchedManifests, copied, err
+}
+
+// Copies the fields that differ from an object to another, removing the fields missing from the source,
+// and returns the differences such as `metadata.name: "slave1" -> "slave"`
+func copyFields(from, to map[string]interface{}, fields [][]string) (differences []string) {
+	for _, field := range fields {
+		value, current := patches.StringField(from, field...), patches.StringField(to, field...)
+		if value == current {
+			continue
+		}
+		differences = append(differences, fmt.Sprintf("%s: %s -> %s", strings.Join(field, "."), formatMergedValue(current, current != ""), formatMergedValue(value, value != "")))
+		if value == "" {
+			unstructured.RemoveNestedField(to, field...)
+			continue
+		}
+		_ = unstructured.SetNestedField(to, value, field...)
+	}
+	return differences
+}
+
+// Returns the kind, version, group, name and namespace of a patch or manifest as Kustomize reports them,
+// such as PtpConfig.v1.ptp.openshift.io/slave1.openshift-ptp or ConfigMap.v1.[noGrp]/config.[noNs]
+func patchIdentity(object map[string]interface{}) string {
+	group, version, found := strings.Cut(patches.StringField(object, "apiVersion"), "/")
+	if !found {
+		group, version = "", group
+	}
+	gvk := resid.Gvk{Group: group, Version: version, Kind: patches.StringField(object, "kind")}
+	return resid.NewResIdWithNamespace(gvk, patches.StringField(object, "metadata", "name"), patches.StringField(object, "metadata", "namespace")).String()
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestPatchIdentity(t *testing.T) {
+	tests := []struct {
+		object map[string]interface{}
+		want   string
+	}{
+		{
+			map[string]interface{}{"apiVersion": "ptp.openshift.io/v1", "kind": "PtpConfig", "metadata": map[string]interface{}{"name": "slave1", "namespace": "openshift-ptp"}},
+			"PtpConfig.v1.ptp.openshift.io/slave1.openshift-ptp",
+		},
+		{
+			map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "config", "namespace": "default"}},
+			"ConfigMap.v1.[noGrp]/config.default",
+		},
+		{
+			map[string]interface{}{"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": "openshift-ptp"}},
+			"Namespace.v1.[noGrp]/openshift-ptp.[noNs]",
+		},
+	}
+	for _, test := range tests {
+		if got := patchIdentity(test.object); got != test.want {
+			t.Errorf("patchIdentity(%v) = %q, want %q", test.object, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/test-network-function/pgt2acm/packages/acmformat"
+	"github.com/test-network-function/pgt2acm/packages/patches"
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"github.com/test-network-function/pgt2acm/packages/report"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Source file annotation holding a list of JSON 6902 operations
+	jsonPatchAnnotation = "pgt2acm.openshift.io/json-patch"
+	// Source file annotation holding the path of a side-car file with a list of JSON 6902 operations,
+	// relative to the PGT file
+	jsonPatchFileAnnotation = "pgt2acm.openshift.io/json-patch-file"
+)
+
+// Returns true if a source file declares JSON 6902 operations
+func hasJSONPatches(sourceFile *pgtformat.SourceFile) bool {
+	annotations, _ := sourceFile.Metadata["annotations"].(map[string]interface{})
+	_, hasInline := annotations[jsonPatchAnnotation]
+	_, hasFile := annotations[jsonPatchFileAnnotation]
+	return hasInline || hasFile
+}
+
+// Returns the JSON 6902 operations declared by a source file, inline and in the side-car file, as a
+// JSON patch targeting the source CR. In a multi-document source CR, the target is the manifest
+// with the overridden name
+func sourceFileJSONPatches(options *conversionOptions, inputFile string, manifests []map[string]interface{}, sourceFile *pgtformat.SourceFile) (jsonPatches []patches.JSONPatch, err error) {
+	annotations, _ := sourceFile.Metadata["annotations"].(map[string]interface{})
+	var operations []map[string]interface{}
+	if value, ok := annotations[jsonPatchAnnotation]; ok {
+		var inlineOperations []map[string]interface{}
+		err = yaml.Unmarshal([]byte(fmt.Sprint(value)), &inlineOperations)
+		if err != nil {
+			return nil, report.Errorf(sourceFilePosition(options, inputFile, sourceFile, "metadata.annotations"),
+				"could not parse the %s annotation of source file %s, err: %s", jsonPatchAnnotation, sourceFile.FileName, err)
+		}
+		operations = append(operations, inlineOperations...)
+	}
+	if value, ok := annotations[jsonPatchFileAnnotation]; ok {
+		sideCarPath := filepath.Join(filepath.Dir(inputFile), fmt.Sprint(value))
+		content, err := os.ReadFile(sideCarPath)
+		if err != nil {
+			return nil, report.Errorf(sourceFilePosition(options, inputFile, sourceFile, "metadata.annotations"), "unable to open file: %s, err: %s", sideCarPath, err)
+		}
+		var fileOperations []map[string]interface{}
+		err = yaml.Unmarshal(content, &fileOperations)
+		if err != nil {
+			return nil, report.Errorf(sourceFilePosition(options, inputFile, sourceFile, "metadata.annotations"), "could not parse the JSON patch file: %s, err: %s", sideCarPath, err)
+		}
+		operations = append(operations, fileOperations...)
+	}
+	if len(operations) == 0 {
+		return nil, nil
+	}
+
+	jsonPatch := patches.JSONPatch{Operations: operations}
+	if len(manifests) > 1 {
+		name, _ := sourceFile.Metadata["name"].(string)
+		for _, manifest := range manifests {
+			manifestMetadata, _ := manifest["metadata"].(map[string]interface{})
+			if name == "" || manifestMetadata["name"] != name {
+				continue
+			}
+			apiVersion, _ := manifest["apiVersion"].(string)
+			jsonPatch.Target.Kind, _ = manifest["kind"].(string)
+			jsonPatch.Target.Name = name
+			jsonPatch.Target.Namespace, _ = manifestMetadata["namespace"].(string)
+			jsonPatch.Target.Version = apiVersion
+			if group, version, found := strings.Cut(apiVersion, "/"); found {
+				jsonPatch.Target.Group, jsonPatch.Target.Version = group, version
+			}
+		}
+		if jsonPatch.Target.Kind == "" {
+			return nil, report.Errorf(sourceFilePosition(options, inputFile, sourceFile, "metadata.name"),
+				"the JSON patches of source file %s must select a manifest of the multi-document source CR with metadata.name", sourceFile.FileName)
+		}
+	}
+	return []patches.JSONPatch{jsonPatch}, nil
+}
+
+// Returns the metadata of a source file without the JSON patch annotations, which are not part of the
+// strategic merge patch
+func metadataWithoutJSONPatches(metadata map[string]interface{}) map[string]interface{} {
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	_, hasInline := annotations[jsonPatchAnnotation]
+	_, hasFile := annotations[jsonPatchFileAnnotation]
+	if !hasInline && !hasFile {
+		return metadata
+	}
+	filteredAnnotations := map[string]interface{}{}
+	for key, value := range annotations {
+		if key != jsonPatchAnnotation && key != jsonPatchFileAnnotation {
+			filteredAnnotations[key] = value
+		}
+	}
+	filtered := map[string]interface{}{}
+	for key, value := range metadata {
+		filtered[key] = value
+	}
+	delete(filtered, "annotations")
+	if len(filteredAnnotations) != 0 {
+		filtered["annotations"] = filteredAnnotations
+	}
+	return filtered
+}
+
+// Converts a source file with JSON 6902 operations. The ACM policy generator only applies strategic
+// merge patches, so the patches of the source file are applied on a copy of the source CR, with the
+// pre-rendering schema if its patches are pre-rendered, and the manifest refers to this copy. As with
+// wrapInPolicy: false, a single-document source CR is renamed to the name and namespace of the patch
+func convertJSONPatches(options *conversionOptions, inputFile, policyName string, sourceFile *pgtformat.SourceFile, newManifest *acmformat.Manifest) (err error) {
+	sourceCRPath := filepath.Join(options.outputDir, newManifest.Path)
+	manifests, err := patches.UnmarshalManifestFile(sourceCRPath)
+	if err != nil {
+		return err
+	}
+	if len(manifests) == 0 {
+		return fmt.Errorf("found empty YAML in the manifest at %s", sourceCRPath)
+	}
+	jsonPatches, err := sourceFileJSONPatches(options, inputFile, manifests, sourceFile)
+	if err != nil {
+		return err
+	}
+	patcher := patches.ManifestPatcher{Manifests: manifests, JSONPatches: jsonPatches}
+	if patch, hasPatch := convertSourceFileToPatch(sourceFile); hasPatch {
+		patcher.Patches = []map[string]interface{}{patch}
+		if len(manifests) == 1 {
+			renameSourceCR(manifests[0], patch)
+		}
+	}
+	preRendered, _ := preRenderingDecision(options, manifests, patcher.Patches)
+	patcher.BuiltInSchemaOnly = !preRendered
+
+	err = patcher.Validate()
+	if err != nil {
+		return err
+	}
+	// The patched copy holds the fixed source CR, it is not copied
+	patchedManifests, _, err := applySourceFilePatches(options, inputFile, sourceFile, &patcher, "")
+	if err != nil {
+		return err
+	}
+
+	// The patched copy is specific to the policy since the patches may differ between policies
+	newManifest.Path = strings.TrimSuffix(newManifest.Path, ".yaml") + "-" + policyName + ".yaml"
+	err = writeManifestsToFile(patchedManifests, filepath.Join(options.outputDir, newManifest.Path))
+	if err != nil {
+		return err
+	}
+	options.report.InfofAt(sourceFilePosition(options, inputFile, sourceFile, ""), "source file %s: JSON patches applied, the patched source CR is written to %s", sourceFile.FileName, newManifest.Path)
+	return nil
+}
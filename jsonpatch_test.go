@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/test-network-function/pgt2acm/packages/acmformat"
+	"github.com/test-network-function/pgt2acm/packages/patches"
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"github.com/test-network-function/pgt2acm/packages/report"
+	"gopkg.in/yaml.v3"
+)
+
+func TestConvertJSONPatchesRenamesSourceCR(t *testing.T) {
+	outputDir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(outputDir, sourceCrPrefix), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceCR, err := os.ReadFile(filepath.Join("test", "init-source-crs", "ConfigMapGeneric.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(outputDir, sourceCrPrefix, "ConfigMapGeneric.yaml"), sourceCR, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceFile := &pgtformat.SourceFile{
+		FileName: "ConfigMapGeneric.yaml",
+		Metadata: map[string]interface{}{
+			"name":        "renamed-config",
+			"annotations": map[string]interface{}{jsonPatchAnnotation: `[{"op": "add", "path": "/data/added", "value": "json"}]`},
+		},
+		Data: map[string]interface{}{"setting": "patched"},
+	}
+	options := &conversionOptions{outputDir: outputDir, report: &report.Report{}, renderedSourceCRs: map[string]string{},
+		pgtNodes: map[*pgtformat.PolicyGenTemplate]*yaml.Node{}, sourceFileNodes: map[*pgtformat.SourceFile]*yaml.Node{}}
+	manifest := &acmformat.Manifest{Path: sourceCrPrefix + "/ConfigMapGeneric.yaml"}
+
+	err = convertJSONPatches(options, "pgt.yaml", "config-policy", sourceFile, manifest)
+	if err != nil {
+		t.Fatalf("convertJSONPatches() error: %s", err)
+	}
+
+	// The source CR takes the name of the source file, as with wrapInPolicy: false
+	if manifest.Path != sourceCrPrefix+"/ConfigMapGeneric-config-policy.yaml" {
+		t.Errorf("manifest path = %s, want %s/ConfigMapGeneric-config-policy.yaml", manifest.Path, sourceCrPrefix)
+	}
+	manifests, err := patches.UnmarshalManifestFile(filepath.Join(outputDir, manifest.Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"setting": "patched", "added": "json"}
+	if len(manifests) != 1 || patches.StringField(manifests[0], "metadata", "name") != "renamed-config" ||
+		patches.StringField(manifests[0], "metadata", "namespace") != "example-ns" || !reflect.DeepEqual(manifests[0]["data"], want) {
+		t.Errorf("patched source CR = %v, want renamed-config in example-ns with the data %v", manifests, want)
+	}
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"flag"
@@ -16,6 +21,7 @@ import (
 	"github.com/test-network-function/pgt2acm/packages/pgtformat"
 	"github.com/test-network-function/pgt2acm/packages/placement"
 	"github.com/test-network-function/pgt2acm/packages/renderpolicies"
+	"github.com/test-network-function/pgt2acm/packages/report"
 	"github.com/test-network-function/pgt2acm/packages/stringhelper"
 	"gopkg.in/yaml.v3"
 )
@@ -38,30 +44,80 @@ func processFlags(inputFile, outputDir, preRenderPatchKindString, sourceCRListSt
 }
 
 func main() {
+	if len(os.Args) > 1 && (os.Args[1] == schemaCommand || os.Args[1] == pruneSchemaCommand) {
+		runCommand := runSchemaCommand
+		if os.Args[1] == pruneSchemaCommand {
+			runCommand = runPruneSchemaCommand
+		}
+		err := runCommand(os.Args[2:])
+		if err != nil {
+			fmt.Printf("Could not generate schema, err: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Defines the input PGT directory or file
 	var inputFile = flag.String("i", "", "the PGT input file")
 	// Defines the output directory for generated ACM templates
 	var outputDir = flag.String("o", "", "the ACMGen output Directory")
 	// Defines the input schema file. Schema allows patching CRDs containing lists of objects
-	var schema = flag.String("s", "", "the optional schema for all non base CRDs")
+	var schema = flag.String("s", "", "the optional schema overriding or extending the bundled schema library")
 	// Defines list of manifest kinds to which to pre-render patches to
-	var preRenderPatchKindString = flag.String("k", "", "the optional list of manifest kinds for which to pre-render patches")
+	var preRenderPatchKindString = flag.String("k", "", "the optional list of manifest kinds for which to pre-render patches, or auto to detect them")
 	// Optionally generates ACM policies for PGT and ACM Gen templates
 	var generateACMPolicies = flag.Bool("g", false, "optionally generates ACM policies for PGT and ACM Gen templates")
 	// Defines ns.yaml file for templates
 	var NSYAML = flag.String("n", fileutils.NamespaceFileName, "the optional ns.yaml file path")
 	// optionally disables generating default placement in ns.yaml
 	var skipDefaultPlacementBindings = flag.Bool("p", false, "optionally disable generating default placement bindings in ns.yaml")
+	// Defines the cluster sets bound to the policy namespaces in ns.yaml
+	var clusterSets = flag.String("cluster-sets", fileutils.DefaultClusterSet, "the comma delimited list of ManagedClusterSets bound to the namespaces of the converted policies in ns.yaml")
 	// Defines source-crs directory location
 	var sourceCRs = flag.String("c", "", "the optional comma delimited list of reference source CRs templates")
 	// Optionally generate placement API template containing toleration for
 	//     - effect: NoSelect
 	//       key: cluster.open-cluster-management.io/unreachable
 	var workaroundPlacement = flag.Bool("w", false, "Optional workaround to generate placement API template containing cluster.open-cluster-management.io/unreachable toleration")
+	// Defines the placement configuration file, generating the placement API templates as with -w
+	var placementConfigFile = flag.String("placement-config", "", "the optional placement configuration file setting the API version, tolerations, cluster sets, number of clusters, prioritizer policy and claim selector of the generated placements, implies -w")
+	// Optionally splits policies containing manifests with different ztp-deploy-waves
+	var splitWaves = flag.Bool(splitWavesFlag, false, "optionally splits policies whose manifests have different ztp-deploy-waves into one policy per wave")
+	// Optionally orders policies with ACM dependencies built from the ztp-deploy-waves
+	var waveDependencies = flag.Bool("wave-dependencies", false, "optionally makes each policy depend on the policies of the previous ztp-deploy-wave in the same namespace")
+	// Defines which PGT labels and annotations are copied to the policies
+	var metadataFilter = flag.String("metadata-filter", defaultMetadataFilter, "the comma delimited list of PGT label and annotation keys copied to the policies, \"*\" matches any string and a \"!\" prefix excludes keys")
+
+	// Optionally compares the PGT and Kustomize merges of the source file overrides
+	var checkMerge = flag.Bool(checkMergeFlag, false, "optionally reports the fields that differ between the source CRs merged by PGT and patched by Kustomize")
+
+	// Optionally adds $patch: replace directives to the patch fields without structural schema
+	var replaceSchemaless = flag.Bool(replaceSchemalessFlag, false, "optionally adds $patch: replace directives to the patch maps without structural schema")
+
+	// Optionally fixes the patches matching no resource of their source CR
+	var fix = flag.String(fixFlag, "", "optionally fixes the patches matching no resource of their source CR: patch rewrites the patch metadata, source-cr renames the closest resource in a copy of the source CR for the policy")
 
 	preRenderPatchKindList, preRenderSourceCRList := processFlags(inputFile, outputDir, preRenderPatchKindString, sourceCRs)
 
 	var err error
+	if *fix != "" && *fix != fixPatch && *fix != fixSourceCR {
+		fmt.Printf("Invalid -%s value: %s, must be %s or %s", fixFlag, *fix, fixPatch, fixSourceCR)
+		os.Exit(1)
+	}
+	clusterSetList, err := parseClusterSets(*clusterSets)
+	if err != nil && *NSYAML != "" && !*skipDefaultPlacementBindings {
+		fmt.Printf("Invalid -cluster-sets value: %q, err: %s", *clusterSets, err)
+		os.Exit(1)
+	}
+	placementConfig := placement.DefaultConfig()
+	if *placementConfigFile != "" {
+		placementConfig, err = placement.LoadConfig(*placementConfigFile)
+		if err != nil {
+			fmt.Printf("Could not load the placement configuration, err: %s", err)
+			os.Exit(1)
+		}
+	}
+
 	if sourceCRs != nil && *sourceCRs != "" {
 		err = fileutils.CopySourceCrs(*inputFile, *outputDir, preRenderSourceCRList)
 		if err != nil {
@@ -76,14 +132,33 @@ func main() {
 		os.Exit(1)
 	}
 	// convert all PGT files
-	err = convertAllPGTFiles(preRenderPatchKindList, allFilesInInputPath, inputFile, outputDir, schema, workaroundPlacement)
+	options := conversionOptions{
+		inputDir:               *inputFile,
+		outputDir:              *outputDir,
+		schema:                 *schema,
+		preRenderPatchKindList: preRenderPatchKindList,
+		workaroundPlacement:    *workaroundPlacement || *placementConfigFile != "",
+		placementConfig:        placementConfig,
+		splitWaves:             *splitWaves,
+		waveDependencies:       *waveDependencies,
+		metadataFilter:         strings.Split(*metadataFilter, ","),
+		checkMerge:             *checkMerge,
+		replaceSchemaless:      *replaceSchemaless,
+		fix:                    *fix,
+		report:                 &report.Report{},
+		renderedSourceCRs:      map[string]string{},
+		pgtNodes:               map[*pgtformat.PolicyGenTemplate]*yaml.Node{},
+		sourceFileNodes:        map[*pgtformat.SourceFile]*yaml.Node{},
+	}
+	convertedGenerators, err := convertAllPGTFiles(&options, allFilesInInputPath)
+	options.report.Print(os.Stdout)
 	if err != nil {
-		fmt.Printf("Could not convert PGT files, err: %s", err)
+		fmt.Printf("Could not convert PGT files:\n%s\n", err)
 		os.Exit(1)
 	}
 
 	if NSYAML != nil && *NSYAML != "" && skipDefaultPlacementBindings != nil {
-		err = fileutils.CopyAndProcessNSAndKustomizationYAML(*NSYAML, *inputFile, *outputDir, *skipDefaultPlacementBindings)
+		err = fileutils.CopyAndProcessNSAndKustomizationYAML(*NSYAML, *inputFile, *outputDir, *skipDefaultPlacementBindings, convertedGenerators, clusterSetList)
 		if err != nil {
 			fmt.Printf("Could not post-process %s and %s files, err: %s", *NSYAML, fileutils.KustomizationFileName, err)
 		}
@@ -104,43 +179,274 @@ func main() {
 	}
 }
 
-func convertAllPGTFiles(preRenderPatchKindList, allFilesInInputPath []string, inputFile, outputDir, schema *string, workaroundPlacement *bool) (err error) {
+const (
+	policyGenTemplateKind = "PolicyGenTemplate"
+	autoPreRenderKinds    = "auto"
+	replaceSchemalessFlag = "replace-schemaless"
+	splitWavesFlag        = "split-waves"
+	defaultMetadataFilter = "*,!kubectl.kubernetes.io/*,!argocd.argoproj.io/*"
+)
+
+// Returns the ManagedClusterSets listed by the -cluster-sets option, without blanks and empty entries.
+// An error is returned if no cluster set is listed
+func parseClusterSets(value string) (clusterSets []string, err error) {
+	for _, clusterSet := range strings.Split(value, ",") {
+		if clusterSet = strings.TrimSpace(clusterSet); clusterSet != "" {
+			clusterSets = append(clusterSets, clusterSet)
+		}
+	}
+	if len(clusterSets) == 0 {
+		return nil, errors.New("at least one ManagedClusterSet must be listed")
+	}
+	return clusterSets, nil
+}
+
+// Options shared by the conversion of all the PGT files
+type conversionOptions struct {
+	// The PGT input file or directory
+	inputDir string
+	// The ACM Gen output directory
+	outputDir string
+	// The optional schema for all non base CRDs
+	schema string
+	// The manifest kinds for which to pre-render patches
+	preRenderPatchKindList []string
+	// Generates a placement API template per policy when set
+	workaroundPlacement bool
+	// The API version, tolerations, cluster sets, number of clusters, prioritizers and claim selector
+	// of the generated placements
+	placementConfig *placement.Config
+	// Splits the policies whose manifests have different ztp-deploy-waves into one policy per wave
+	splitWaves bool
+	// Makes the policies depend on the policies of the previous ztp-deploy-wave in the same namespace
+	waveDependencies bool
+	// Compares the PGT and Kustomize merges of the source file overrides
+	checkMerge bool
+	// Adds $patch: replace directives to the patch fields without structural schema
+	replaceSchemaless bool
+	// Fixes the patches matching no resource of their source CR, by rewriting the patch or the source CR
+	fix string
+	// The YAML nodes of the PGTs and of their source files, locating the messages in the input files
+	pgtNodes        map[*pgtformat.PolicyGenTemplate]*yaml.Node
+	sourceFileNodes map[*pgtformat.SourceFile]*yaml.Node
+	// Patterns selecting the PGT labels and annotations keys copied to the policies
+	metadataFilter []string
+	// Collects the decisions and warnings of the conversion
+	report *report.Report
+	// The source CRs with the $mcp keyword already rendered, indexed by source CR path and MCP
+	renderedSourceCRs map[string]string
+}
+
+// Renders the $mcp keyword in a source CR once per MCP and returns the path of the rendered source CR
+func renderMCPLines(options *conversionOptions, sourceCRPath, mcp string) (renderedPath string, err error) {
+	cacheKey := sourceCRPath + "|" + mcp
+	if renderedPath, ok := options.renderedSourceCRs[cacheKey]; ok {
+		return renderedPath, nil
+	}
+	renderedPath, err = fileutils.RenderMCPLines(sourceCRPath, mcp)
+	if err != nil {
+		return renderedPath, err
+	}
+	options.renderedSourceCRs[cacheKey] = renderedPath
+	return renderedPath, nil
+}
+
+// An ACM Gen template converted from a PGT, pending to be written
+type convertedTemplate struct {
+	// The PGT input file
+	inputFile     string
+	outputFile    string
+	policyGenTemp *pgtformat.PolicyGenTemplate
+	// The PGT document, holding the comments copied to the template
+	pgtDocument *yaml.Node
+	template    *acmformat.AcmGenTemplate
+}
+
+// Converts all PGT files and returns, for each converted file relative to the input directory, the
+// generated files relative to the output directory. The ACM Gen templates are written once all
+// the PGTs are converted, since dependencies between policies can span several templates
+func convertAllPGTFiles(options *conversionOptions, allFilesInInputPath []string) (convertedGenerators map[string]fileutils.ConvertedFiles, err error) {
+	convertedGenerators = map[string]fileutils.ConvertedFiles{}
+	var templates []convertedTemplate
 	for _, file := range allFilesInInputPath {
-		var kindType fileutils.KindType
-		kindType, err = fileutils.GetManifestKind(file)
+		var kindTypes []fileutils.KindType
+		kindTypes, err = fileutils.GetManifestKinds(file)
 		if err != nil {
-			return fmt.Errorf("could not get manifest kind for file:%s, err: %s", file, err)
+			return convertedGenerators, fmt.Errorf("could not get manifest kind for file:%s, err: %s", file, err)
 		}
-		if kindType.Kind != "PolicyGenTemplate" {
+		if !containsKind(kindTypes, policyGenTemplateKind) {
 			continue
 		}
 		// Get the relative path
 		var relativePath string
-		relativePath, err = filepath.Rel(*inputFile, file)
+		relativePath, err = filepath.Rel(options.inputDir, file)
 		if err != nil {
-			return fmt.Errorf("error getting relative path, err:%s", err)
+			return convertedGenerators, fmt.Errorf("error getting relative path, err:%s", err)
 		}
-		err = convertPGTtoACM(*outputDir, file, filepath.Join(*outputDir, fileutils.PrefixLastPathComponent(relativePath, fileutils.ACMPrefix)), *schema, preRenderPatchKindList, workaroundPlacement)
+		var outputFiles fileutils.ConvertedFiles
+		var fileTemplates []convertedTemplate
+		outputFiles, fileTemplates, err = convertPGTFile(options, file, relativePath)
 		if err != nil {
-			return fmt.Errorf("failed to convert PGT to ACMGen, err=%s", err)
+			return convertedGenerators, report.Wrapf(err, report.Position{File: file}, "failed to convert PGT to ACMGen")
 		}
+		convertedGenerators[relativePath] = outputFiles
+		templates = append(templates, fileTemplates...)
+	}
+
+	if options.waveDependencies {
+		addWaveDependencies(options, templates)
+	}
+	for templateIndex := range templates {
+		err = writeConvertedTemplateToFile(templates[templateIndex].policyGenTemp, templates[templateIndex].pgtDocument, templates[templateIndex].template, templates[templateIndex].outputFile)
+		if err != nil {
+			return convertedGenerators, err
+		}
+	}
+	return convertedGenerators, nil
+}
+
+func containsKind(kindTypes []fileutils.KindType, kind string) bool {
+	for _, kindType := range kindTypes {
+		if kindType.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Converts every PolicyGenTemplate document of a file to its own ACM Gen Template file. A file
+// holding a single PGT document keeps the "acm-" prefixed name. Otherwise each PGT document is
+// written to a file suffixed with the PGT name and the documents of other kinds are copied
+// unchanged to the "acm-" prefixed file. PGTs not wrapped in policies are converted to a resources
+// directory named after the file instead. Returns the generated files relative to the output directory
+// and the converted templates, which are not written yet
+func convertPGTFile(options *conversionOptions, inputFile, relativePath string) (outputFiles fileutils.ConvertedFiles, templates []convertedTemplate, err error) {
+	documents, err := readYAMLDocuments(inputFile)
+	if err != nil {
+		return outputFiles, templates, err
+	}
+	prefixedPath := fileutils.PrefixLastPathComponent(relativePath, fileutils.ACMPrefix)
+	usedFileNames := map[string]bool{}
+	var otherDocuments []*yaml.Node
+	for _, document := range documents {
+		kindType := fileutils.KindType{}
+		err = document.Decode(&kindType)
+		if err != nil {
+			return outputFiles, templates, report.Errorf(nodePosition(inputFile, document), "could not get the kind of the document: %s", err)
+		}
+		if kindType.Kind != policyGenTemplateKind {
+			otherDocuments = append(otherDocuments, document)
+			continue
+		}
+		policyGenTemp := pgtformat.PolicyGenTemplate{}
+		err = document.Decode(&policyGenTemp)
+		if err != nil {
+			return outputFiles, templates, report.Errorf(nodePosition(inputFile, document), "could not unmarshal PolicyGenTemplate data: %s", err)
+		}
+		registerPGTNodes(options, document, &policyGenTemp)
+		reportUnrepresentedFields(options, inputFile, &policyGenTemp)
+		outputPath := prefixedPath
+		if len(documents) > 1 {
+			outputPath = uniqueDocumentFileName(prefixedPath, policyGenTemp.Metadata.Name, usedFileNames)
+		}
+		if !policyGenTemp.Spec.WrapInPolicy {
+			resourcesDir := strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+			err = convertPGTtoResources(options, inputFile, &policyGenTemp, filepath.Join(options.outputDir, resourcesDir))
+			if err != nil {
+				return outputFiles, templates, report.Wrapf(err, pgtPosition(options, inputFile, &policyGenTemp), "could not convert PolicyGenTemplate %s", policyGenTemp.Metadata.Name)
+			}
+			outputFiles.Resources = append(outputFiles.Resources, resourcesDir)
+			continue
+		}
+		var template *acmformat.AcmGenTemplate
+		template, err = convertPGTtoACM(options, inputFile, &policyGenTemp)
+		if err != nil {
+			return outputFiles, templates, report.Wrapf(err, pgtPosition(options, inputFile, &policyGenTemp), "could not convert PolicyGenTemplate %s", policyGenTemp.Metadata.Name)
+		}
+		templates = append(templates, convertedTemplate{inputFile: inputFile, outputFile: filepath.Join(options.outputDir, outputPath),
+			policyGenTemp: &policyGenTemp, pgtDocument: document, template: template})
+		outputFiles.Generators = append(outputFiles.Generators, outputPath)
+		outputFiles.Namespaces = append(outputFiles.Namespaces, template.PolicyDefaults.Namespace)
+	}
+	if len(otherDocuments) == 0 {
+		return outputFiles, templates, nil
+	}
+	err = writeDocumentsToFile(otherDocuments, filepath.Join(options.outputDir, prefixedPath))
+	if err != nil {
+		return outputFiles, templates, err
+	}
+	outputFiles.Generators = append(outputFiles.Generators, prefixedPath)
+	return outputFiles, templates, nil
+}
+
+// Reads all the non-empty YAML documents in a file
+func readYAMLDocuments(inputFile string) (documents []*yaml.Node, err error) {
+	content, err := os.ReadFile(inputFile)
+	if err != nil {
+		return documents, fmt.Errorf("unable to open file: %s, err: %s ", inputFile, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		document := &yaml.Node{}
+		err = decoder.Decode(document)
+		if errors.Is(err, io.EOF) {
+			return documents, nil
+		}
+		if err != nil {
+			return documents, fmt.Errorf("could not parse %s as yaml: %s", inputFile, err)
+		}
+		if len(document.Content) == 0 || document.Content[0].Tag == "!!null" {
+			continue
+		}
+		documents = append(documents, document)
 	}
-	return nil
 }
 
-// Converts an PGT file to a ACM Gen Template file
-func convertPGTtoACM(outputDir, inputFile, outputFile, schema string, preRenderPatchKindList []string, workaroundPlacement *bool) (err error) {
-	policyGenFileContent, err := os.ReadFile(inputFile)
+// Suffixes a file name with the name of the document it holds, adding an index if the name was already used
+func uniqueDocumentFileName(filePath, documentName string, usedFileNames map[string]bool) string {
+	extension := filepath.Ext(filePath)
+	base := strings.TrimSuffix(filePath, extension)
+	if documentName != "" {
+		base += "-" + documentName
+	}
+	candidate := base + extension
+	for index := 2; usedFileNames[candidate]; index++ {
+		candidate = fmt.Sprintf("%s-%d%s", base, index, extension)
+	}
+	usedFileNames[candidate] = true
+	return candidate
+}
+
+// Writes YAML documents unchanged to a file
+func writeDocumentsToFile(documents []*yaml.Node, outputFile string) (err error) {
+	var content bytes.Buffer
+	content.WriteString("---\n")
+	encoder := yaml.NewEncoder(&content)
+	for _, document := range documents {
+		err = encoder.Encode(document)
+		if err != nil {
+			return fmt.Errorf("could not marshall document, err: %s", err)
+		}
+	}
+	err = encoder.Close()
 	if err != nil {
-		return fmt.Errorf("unable to open file: %s, err: %s ", inputFile, err)
+		return fmt.Errorf("could not marshall document, err: %s", err)
 	}
-	policyGenTemp := pgtformat.PolicyGenTemplate{}
 
-	err = yaml.Unmarshal(policyGenFileContent, &policyGenTemp)
+	err = os.MkdirAll(filepath.Dir(outputFile), fileutils.DefaultDirWritePermissions)
 	if err != nil {
-		return fmt.Errorf("could not unmarshal PolicyGenTemplate data from %s: %s", inputFile, err)
+		return err
+	}
+	err = os.WriteFile(outputFile, content.Bytes(), fileutils.DefaultFileWritePermissions)
+	if err != nil {
+		return fmt.Errorf("error writing to file: %s, err: %s", outputFile, err)
 	}
+	fmt.Printf("Wrote unconverted documents: %s\n", outputFile)
+	return nil
+}
 
+// Converts a PGT to a ACM Gen Template
+func convertPGTtoACM(options *conversionOptions, inputFile string, policyGenTemp *pgtformat.PolicyGenTemplate) (acmGenTemp *acmformat.AcmGenTemplate, err error) {
 	rootName := policyGenTemp.Metadata.Name
 	acmGenTempConversion := acmformat.AcmGenTemplate{}
 
@@ -155,48 +461,105 @@ func convertPGTtoACM(outputDir, inputFile, outputFile, schema string, preRenderP
 	}
 
 	sort.Strings(seenPoliciesSorted)
+	var newPolicies []acmformat.PolicyConfig
 	for _, policyName := range seenPoliciesSorted {
-		newPolicy := convertPGTPolicyToACMGenPolicy(&policyGenTemp, rootName, policyName, outputDir)
-		acmGenTempConversion.Policies = append(acmGenTempConversion.Policies, newPolicy)
-		var labelSelector map[string]interface{}
-		labelSelector, err = labels.OutputGeneric(labels.LabelToSelector(policyGenTemp.Spec.BindingRules,
-			policyGenTemp.Spec.BindingExcludedRules))
+		var policies []acmformat.PolicyConfig
+		policies, err = convertPGTPolicyToACMGenPolicy(options, inputFile, policyGenTemp, rootName, policyName)
 		if err != nil {
-			return err
+			return acmGenTemp, err
 		}
+		newPolicies = append(newPolicies, policies...)
+	}
+	acmGenTempConversion.Policies = append(acmGenTempConversion.Policies, newPolicies...)
 
-		// Convert Miscelanous fields
-		convertSimpleMiscellaneousFields(&policyGenTemp, &acmGenTempConversion, rootName)
+	// Convert Miscelanous fields
+	convertSimpleMiscellaneousFields(policyGenTemp, &acmGenTempConversion, rootName)
 
-		if workaroundPlacement != nil && !*workaroundPlacement {
-			acmGenTempConversion.PolicyDefaults.Placement.LabelSelector = labelSelector
-		} else {
-			// starts creating child policies as soon as the managed cluster starts installing
-			var placementFilepathRelative string
-			placementFilepathRelative, err = placement.GeneratePlacementFile(newPolicy.Name, acmGenTempConversion.PolicyDefaults.Namespace, outputDir, labelSelector)
+	err = convertPlacement(options, policyGenTemp, &acmGenTempConversion)
+	if err != nil {
+		return acmGenTemp, report.Errorf(pgtPosition(options, inputFile, policyGenTemp), "%s", err)
+	}
+
+	if options.checkMerge {
+		checkMergeEquivalence(options, inputFile, policyGenTemp)
+	}
+
+	// Apply patches on ACMGen since it is not yet supported officially
+	for policyIndex := range acmGenTempConversion.Policies {
+		for manifestIndex := range acmGenTempConversion.Policies[policyIndex].Manifests {
+			manifest := &acmGenTempConversion.Policies[policyIndex].Manifests[manifestIndex]
+			err = RenderPatchesInManifestForSpecifiedKinds(options, inputFile, policyGenTemp, acmGenTempConversion.Policies[policyIndex].Name, manifest)
 			if err != nil {
-				return fmt.Errorf("error when generating placement file, err: %s", err)
+				return acmGenTemp, report.Wrapf(err, sourceFilePosition(options, inputFile, &policyGenTemp.Spec.SourceFiles[manifest.SourceFileIndex], ""),
+					"could not render patches in manifest %s", manifest.Path)
 			}
-			acmGenTempConversion.PolicyDefaults.Placement.PlacementPath = placementFilepathRelative
-		}
-
-		// Apply patches on ACMGen since it is not yet supported officially
-		if len(acmGenTempConversion.Policies) > 0 {
-			for policyIndex := range acmGenTempConversion.Policies {
-				for manifestIndex := range acmGenTempConversion.Policies[policyIndex].Manifests {
-					err = RenderPatchesInManifestForSpecifiedKinds(&policyGenTemp, &acmGenTempConversion, policyIndex, manifestIndex, outputDir, schema, preRenderPatchKindList)
-					if err != nil {
-						return fmt.Errorf("could not render patches in manifest, err:%s", err)
-					}
-				}
+		}
+	}
+	copyPGTMetadata(options, inputFile, policyGenTemp, &acmGenTempConversion)
+	return &acmGenTempConversion, nil
+}
+
+// Converts the PGT binding rules to the placement of the policies. All the policies of a PGT share the
+// same binding rules, so a single placement is generated with the -w or -placement-config options and
+// shared by all the policies through the policy defaults
+func convertPlacement(options *conversionOptions, policyGenTemp *pgtformat.PolicyGenTemplate, acmGenTempConversion *acmformat.AcmGenTemplate) (err error) {
+	labelSelector, err := labels.OutputGeneric(labels.LabelToSelector(policyGenTemp.Spec.BindingRules,
+		policyGenTemp.Spec.BindingExcludedRules))
+	if err != nil {
+		return err
+	}
+
+	if !options.workaroundPlacement {
+		acmGenTempConversion.PolicyDefaults.Placement.LabelSelector = labelSelector
+		return nil
+	}
+	// starts creating child policies as soon as the managed cluster starts installing
+	placementFilepathRelative, err := placement.GeneratePlacementFile(policyGenTemp.Metadata.Name, acmGenTempConversion.PolicyDefaults.Namespace, options.outputDir, labelSelector, options.placementConfig)
+	if err != nil {
+		return fmt.Errorf("error when generating placement file, err: %s", err)
+	}
+	acmGenTempConversion.PolicyDefaults.Placement.PlacementPath = placementFilepathRelative
+	return nil
+}
+
+// Copies the PGT labels and annotations selected by the metadata filter to the policies. Policies
+// with their own annotations, such as the wave, also get the PGT annotations since ACM does not
+// merge them with the default ones. copyPolicyMetadata keeps them on the replicated policies
+func copyPGTMetadata(options *conversionOptions, inputFile string, policyGenTemp *pgtformat.PolicyGenTemplate, acmGenTempConversion *acmformat.AcmGenTemplate) {
+	policyLabels, excludedLabels := labels.FilterKeys(policyGenTemp.Metadata.Labels, options.metadataFilter)
+	policyAnnotations, excludedAnnotations := labels.FilterKeys(policyGenTemp.Metadata.Annotations, options.metadataFilter)
+	excludedLabels = append(excludedLabels, excludedAnnotations...)
+	if len(excludedLabels) != 0 {
+		options.report.InfofAt(pgtPosition(options, inputFile, policyGenTemp), "PolicyGenTemplate %s: the metadata keys %s are filtered out and not copied to the policies",
+			policyGenTemp.Metadata.Name, strings.Join(excludedLabels, ", "))
+	}
+	if len(policyLabels)+len(policyAnnotations) == 0 {
+		return
+	}
+
+	acmGenTempConversion.PolicyDefaults.PolicyLabels = policyLabels
+	acmGenTempConversion.PolicyDefaults.PolicyAnnotations = policyAnnotations
+	acmGenTempConversion.PolicyDefaults.CopyPolicyMetadata = true
+	for policyIndex := range acmGenTempConversion.Policies {
+		policy := &acmGenTempConversion.Policies[policyIndex]
+		if policy.PolicyAnnotations == nil {
+			continue
+		}
+		for key, value := range policyAnnotations {
+			if _, ok := policy.PolicyAnnotations[key]; !ok {
+				policy.PolicyAnnotations[key] = value
 			}
 		}
 	}
-	return writeConvertedTemplateToFile(&policyGenTemp, &acmGenTempConversion, outputFile)
 }
 
-func writeConvertedTemplateToFile(policyGenTemp *pgtformat.PolicyGenTemplate, acmGenTempConversion *acmformat.AcmGenTemplate, outputFile string) (err error) {
-	convertedContent, err := yaml.Marshal(acmGenTempConversion)
+func writeConvertedTemplateToFile(policyGenTemp *pgtformat.PolicyGenTemplate, pgtDocument *yaml.Node, acmGenTempConversion *acmformat.AcmGenTemplate, outputFile string) (err error) {
+	// The template is marshalled from YAML nodes to keep the comments of the PGT
+	templateNode, err := templateNodeWithComments(pgtDocument, acmGenTempConversion)
+	if err != nil {
+		return fmt.Errorf("could not marshall acm profile, err: %s", err)
+	}
+	convertedContent, err := yaml.Marshal(templateNode)
 	if err != nil {
 		return fmt.Errorf("could not marshall acm profile, err: %s", err)
 	}
@@ -220,23 +583,30 @@ func writeConvertedTemplateToFile(policyGenTemp *pgtformat.PolicyGenTemplate, ac
 	return nil
 }
 
-// Renders patches in manifest
-func RenderPatchesInManifestForSpecifiedKinds(policyGenTemp *pgtformat.PolicyGenTemplate, acmGenTempConversion *acmformat.AcmGenTemplate, policyIndex, manifestIndex int, outputDir, schema string, kindsToRender []string) error {
-	pathRelativeToOutputDir := filepath.Join(outputDir, acmGenTempConversion.Policies[policyIndex].Manifests[manifestIndex].Path)
+// Renders the $mcp keyword in the source CR of a manifest and pre-renders its patches if the manifest kind
+// is one of the kinds to render. This must be done once per manifest: the manifest then refers to the
+// rendered source CR and its patches are replaced by the patched source CR
+func RenderPatchesInManifestForSpecifiedKinds(options *conversionOptions, inputFile string, policyGenTemp *pgtformat.PolicyGenTemplate, policyName string, manifest *acmformat.Manifest) error {
+	pathRelativeToOutputDir := filepath.Join(options.outputDir, manifest.Path)
 
-	renamedpathRelativeToOutputDir, err := fileutils.RenderMCPLines(pathRelativeToOutputDir, policyGenTemp.Spec.Mcp)
+	renamedpathRelativeToOutputDir, err := renderMCPLines(options, pathRelativeToOutputDir, policyGenTemp.Spec.Mcp)
 	if err != nil {
 		return fmt.Errorf("cannot render MCP lines, err:%s", err)
 	}
-	relativeManifestPath, err := filepath.Rel(outputDir, renamedpathRelativeToOutputDir)
+	relativeManifestPath, err := filepath.Rel(options.outputDir, renamedpathRelativeToOutputDir)
 	if err != nil {
-		return fmt.Errorf("cannot get the relative path from path: %s and directory: %s, err:%s", renamedpathRelativeToOutputDir, outputDir, err)
+		return fmt.Errorf("cannot get the relative path from path: %s and directory: %s, err:%s", renamedpathRelativeToOutputDir, options.outputDir, err)
 	}
 
 	// we switch to using the renamed manifest file with MCP line commented out
-	acmGenTempConversion.Policies[policyIndex].Manifests[manifestIndex].Path = relativeManifestPath
+	manifest.Path = relativeManifestPath
 	pathRelativeToOutputDir = renamedpathRelativeToOutputDir
 
+	// Patch files only if needed
+	if len(manifest.Patches) == 0 {
+		return nil
+	}
+
 	// Unmarshal the manifest in order to check for metadata patch replacement
 	manifestFile, err := patches.UnmarshalManifestFile(pathRelativeToOutputDir)
 	if err != nil {
@@ -247,54 +617,121 @@ func RenderPatchesInManifestForSpecifiedKinds(policyGenTemp *pgtformat.PolicyGen
 		return fmt.Errorf("found empty YAML in the manifest at %s", pathRelativeToOutputDir)
 	}
 
-	kind, err := fileutils.GetManifestKind(pathRelativeToOutputDir)
-	if err != nil {
-		return fmt.Errorf("could not get manifest kind for file: %s, err: %s", pathRelativeToOutputDir, err)
+	patcher := patches.ManifestPatcher{Manifests: manifestFile, Patches: manifest.Patches}
+	const errTemplate = `failed to process the manifest at "%s": %w`
+	if len(manifestFile) > 1 {
+		// The patches of a multi-document manifest must keep the identity of the document they
+		// target, the ACM policy generator does not select it by name
+		err = patcher.Validate()
+		if err != nil {
+			return fmt.Errorf(errTemplate, pathRelativeToOutputDir, err)
+		}
 	}
 
-	if !stringhelper.StringInSlice[string](kindsToRender, kind.Kind, false) {
-		return nil
+	sourceFile := &policyGenTemp.Spec.SourceFiles[manifest.SourceFileIndex]
+	preRendered := needsPreRendering(options, inputFile, sourceFile, manifestFile, manifest)
+	if options.replaceSchemaless {
+		err = insertReplaceDirectives(options, inputFile, sourceFile, manifestFile, manifest, preRendered)
+		if err != nil {
+			return err
+		}
 	}
-
-	// Patch files only if needed
-	if len(acmGenTempConversion.Policies[policyIndex].Manifests[manifestIndex].Patches) == 0 {
+	if !preRendered {
 		return nil
 	}
-
-	patcher := patches.ManifestPatcher{Manifests: manifestFile, Patches: acmGenTempConversion.Policies[policyIndex].Manifests[manifestIndex].Patches}
-	const errTemplate = `failed to process the manifest at "%s": %w`
-
-	err = patcher.Validate()
+	err = preRenderPatches(options, inputFile, sourceFile, &patcher, manifest, policyName)
 	if err != nil {
 		return fmt.Errorf(errTemplate, pathRelativeToOutputDir, err)
 	}
+	return nil
+}
 
-	patchedFiles, err := patcher.ApplyPatches(schema)
+// Replaces the patches of a manifest with the patched documents they target. The documents keep their
+// identity in a multi-document manifest, the ACM policy generator sets it for a single document. A source
+// CR fixed by -fix source-cr is copied for the policy and the manifest refers to the copy
+func preRenderPatches(options *conversionOptions, inputFile string, sourceFile *pgtformat.SourceFile, patcher *patches.ManifestPatcher, manifest *acmformat.Manifest, policyName string) error {
+	err := patcher.Validate()
 	if err != nil {
-		return fmt.Errorf(errTemplate, pathRelativeToOutputDir, err)
+		return err
 	}
-	delete(patchedFiles[0], "apiVersion")
-	delete(patchedFiles[0], "kind")
+	copyPath := strings.TrimSuffix(manifest.Path, ".yaml") + "-" + policyName + ".yaml"
+	patchedFiles, copied, err := applySourceFilePatches(options, inputFile, sourceFile, patcher, filepath.Join(options.outputDir, copyPath))
+	if err != nil {
+		return err
+	}
+	if copied {
+		manifest.Path = copyPath
+	}
+	manifest.Patches, err = patches.PatchedTargets(patcher.Patches, patchedFiles)
+	if err != nil {
+		return err
+	}
+	if len(patcher.Manifests) == 1 {
+		delete(manifest.Patches[0], "apiVersion")
+		delete(manifest.Patches[0], "kind")
+	}
+	return nil
+}
+
+// Returns whether the patches of a manifest are pre-rendered: in auto mode, when the ACM policy generator
+// would not merge them as intended, otherwise when the manifest kind is one of the kinds to pre-render
+func needsPreRendering(options *conversionOptions, inputFile string, sourceFile *pgtformat.SourceFile, manifestFile []map[string]interface{}, manifest *acmformat.Manifest) bool {
+	needed, reason := preRenderingDecision(options, manifestFile, manifest.Patches)
+	if reason == "" {
+		return needed
+	}
+	if needed {
+		options.report.InfofAt(sourceFilePosition(options, inputFile, sourceFile, ""), "manifest %s: patches pre-rendered, %s", manifest.Path, reason)
+	} else {
+		options.report.InfofAt(sourceFilePosition(options, inputFile, sourceFile, ""), "manifest %s: patches not pre-rendered, %s", manifest.Path, reason)
+	}
+	return needed
+}
 
-	acmGenTempConversion.Policies[policyIndex].Manifests[manifestIndex].Patches = patchedFiles
+// Adds $patch: replace directives to the patch maps without structural schema in the schema applying the
+// patches: the pre-rendering schema, or the Kustomize built-in schema used by the ACM policy generator
+func insertReplaceDirectives(options *conversionOptions, inputFile string, sourceFile *pgtformat.SourceFile, manifestFile []map[string]interface{}, manifest *acmformat.Manifest, preRendered bool) error {
+	patcher := patches.ManifestPatcher{Manifests: manifestFile, Patches: manifest.Patches, BuiltInSchemaOnly: !preRendered}
+	fieldPaths, err := patcher.InsertReplaceDirectives(options.schema)
+	if err != nil {
+		return fmt.Errorf("failed to insert the replace directives in the patches of %s, err: %s", manifest.Path, err)
+	}
+	for _, fieldPath := range fieldPaths {
+		options.report.InfofAt(sourceFilePosition(options, inputFile, sourceFile, fieldPath), "manifest %s: $patch: replace added to %s, the field has no structural schema", manifest.Path, fieldPath)
+	}
 	return nil
 }
 
+// Returns whether patches are pre-rendered and, in auto mode, why. In auto mode, the patches of the kinds
+// listed next to auto are always pre-rendered
+func preRenderingDecision(options *conversionOptions, manifestFile, manifestPatches []map[string]interface{}) (needed bool, reason string) {
+	kind, _ := manifestFile[0]["kind"].(string)
+	listed := stringhelper.StringInSlice(options.preRenderPatchKindList, kind, false)
+	if !stringhelper.StringInSlice(options.preRenderPatchKindList, autoPreRenderKinds, false) {
+		return listed, ""
+	}
+	if listed {
+		return true, fmt.Sprintf("the kind %s is listed with -k", kind)
+	}
+	return patches.NeedsPreRendering(manifestFile, manifestPatches)
+}
+
 const (
 	waveAnnotationKey = "ran.openshift.io/ztp-deploy-wave"
 	sourceCrPrefix    = "source-crs"
 )
 
-// Converts PGT policy to ACM Gen policy
-func convertPGTPolicyToACMGenPolicy(policyGenTemp *pgtformat.PolicyGenTemplate, rootName, policyName, outputDir string) (newPolicy acmformat.PolicyConfig) {
+// Converts PGT policy to ACM Gen policies. A single policy is returned unless the policy is split by deploy wave
+func convertPGTPolicyToACMGenPolicy(options *conversionOptions, inputFile string, policyGenTemp *pgtformat.PolicyGenTemplate, rootName, policyName string) (newPolicies []acmformat.PolicyConfig, err error) {
+	newPolicy := acmformat.PolicyConfig{}
 	newPolicy.Name = rootName + "-" + policyName
-	newPolicy.PolicyAnnotations = make(map[string]string)
-	wave := ""
+	// ztp-deploy-wave of each manifest, indexed by manifest
+	manifestWaves := map[int]int{}
 	for srcFileIndex := range policyGenTemp.Spec.SourceFiles {
 		if policyGenTemp.Spec.SourceFiles[srcFileIndex].PolicyName != policyName {
 			continue
 		}
-		newManifest := acmformat.Manifest{Path: sourceCrPrefix + "/" + policyGenTemp.Spec.SourceFiles[srcFileIndex].FileName}
+		newManifest := acmformat.Manifest{Path: sourceCrPrefix + "/" + policyGenTemp.Spec.SourceFiles[srcFileIndex].FileName, SourceFileIndex: srcFileIndex}
 
 		// Setting EvaluationInterval
 		if policyGenTemp.Spec.SourceFiles[srcFileIndex].EvaluationInterval.Compliant != pgtformat.UnsetStringValue {
@@ -305,45 +742,215 @@ func convertPGTPolicyToACMGenPolicy(policyGenTemp *pgtformat.PolicyGenTemplate,
 			newPolicy.EvaluationInterval.NonCompliant = policyGenTemp.Spec.SourceFiles[srcFileIndex].EvaluationInterval.NonCompliant
 		}
 
-		newPatch := make(map[string]interface{})
-		hasPatch := false
-		if len(policyGenTemp.Spec.SourceFiles[srcFileIndex].Metadata) != 0 {
-			hasPatch = true
-			newPatch["metadata"] = policyGenTemp.Spec.SourceFiles[srcFileIndex].Metadata
+		// Setting per manifest ComplianceType and RemediationAction
+		if policyGenTemp.Spec.SourceFiles[srcFileIndex].ComplianceType != pgtformat.UnsetStringValue {
+			newManifest.ComplianceType = policyGenTemp.Spec.SourceFiles[srcFileIndex].ComplianceType
 		}
-		if len(policyGenTemp.Spec.SourceFiles[srcFileIndex].Spec) != 0 {
-			hasPatch = true
-			newPatch["spec"] = policyGenTemp.Spec.SourceFiles[srcFileIndex].Spec
+
+		if policyGenTemp.Spec.SourceFiles[srcFileIndex].RemediationAction != pgtformat.UnsetStringValue {
+			newManifest.RemediationAction = policyGenTemp.Spec.SourceFiles[srcFileIndex].RemediationAction
+		}
+
+		pathRelativeToOutputDir := filepath.Join(options.outputDir, newManifest.Path)
+		if isStatusCheck(pathRelativeToOutputDir, &policyGenTemp.Spec.SourceFiles[srcFileIndex]) {
+			err = convertStatusCheck(options, inputFile, newPolicy.Name, &policyGenTemp.Spec.SourceFiles[srcFileIndex], &newManifest)
+			if err != nil {
+				return newPolicies, report.Wrapf(err, sourceFilePosition(options, inputFile, &policyGenTemp.Spec.SourceFiles[srcFileIndex], ""),
+					"could not convert status check: %s", newManifest.Path)
+			}
+		} else if hasJSONPatches(&policyGenTemp.Spec.SourceFiles[srcFileIndex]) {
+			err = convertJSONPatches(options, inputFile, newPolicy.Name, &policyGenTemp.Spec.SourceFiles[srcFileIndex], &newManifest)
+			if err != nil {
+				return newPolicies, report.Wrapf(err, sourceFilePosition(options, inputFile, &policyGenTemp.Spec.SourceFiles[srcFileIndex], ""),
+					"could not apply the JSON patches: %s", newManifest.Path)
+			}
+		} else if newPatch, hasPatch := convertSourceFileToPatch(&policyGenTemp.Spec.SourceFiles[srcFileIndex]); hasPatch {
+			newManifest.Patches = append(newManifest.Patches, newPatch)
+		}
+		checkSourceFileData(options, inputFile, pathRelativeToOutputDir, &policyGenTemp.Spec.SourceFiles[srcFileIndex])
+
+		if wave, found := sourceFileWave(pathRelativeToOutputDir, &policyGenTemp.Spec.SourceFiles[srcFileIndex]); found {
+			manifestWaves[len(newPolicy.Manifests)] = wave
+		}
+		newPolicy.Manifests = append(newPolicy.Manifests, newManifest)
+	}
+	newPolicies = applyPolicyWaves(options, policyPosition(options, inputFile, policyGenTemp, &newPolicy), &newPolicy, manifestWaves)
+	for policyIndex := range newPolicies {
+		hoistManifestConfigurationPolicyOptions(&newPolicies[policyIndex])
+	}
+	return newPolicies, nil
+}
+
+// Returns the ztp-deploy-wave of a source CR. As with PGT, a wave set in the metadata patch of the
+// source file takes precedence over the wave of the source CR
+func sourceFileWave(sourceCRPath string, sourceFile *pgtformat.SourceFile) (wave int, found bool) {
+	if annotations, ok := sourceFile.Metadata["annotations"].(map[string]interface{}); ok {
+		if value, ok := annotations[waveAnnotationKey]; ok {
+			return parseWave(fmt.Sprint(value))
+		}
+	}
+	annotations, err := fileutils.GetAnnotationsOnly(sourceCRPath)
+	if err != nil {
+		fmt.Printf("could not get annotations from manifest:%s, err: %s\n", sourceFile.FileName, err)
+		return 0, false
+	}
+	return parseWave(annotations.Metadata.Annotations[waveAnnotationKey])
+}
+
+func parseWave(value string) (wave int, found bool) {
+	if value == "" || !stringhelper.IsNumber(value) {
+		return 0, false
+	}
+	wave, err := strconv.Atoi(value)
+	return wave, err == nil
+}
+
+// Applies the PGT wave rules to a converted policy: the policy wave is the lowest wave of its
+// manifests. If manifests have different waves and splitWaves is set, the policy is split into one
+// policy per wave. The lowest wave policy keeps the policy name and the manifests without a wave
+func applyPolicyWaves(options *conversionOptions, position report.Position, newPolicy *acmformat.PolicyConfig, manifestWaves map[int]int) (newPolicies []acmformat.PolicyConfig) {
+	waveSet := map[int]bool{}
+	for _, wave := range manifestWaves {
+		waveSet[wave] = true
+	}
+	var waves []int
+	for wave := range waveSet {
+		waves = append(waves, wave)
+	}
+	sort.Ints(waves)
+	if len(waves) == 0 {
+		return []acmformat.PolicyConfig{*newPolicy}
+	}
+
+	lowestWave := waves[0]
+	if len(waves) == 1 || !options.splitWaves {
+		newPolicy.PolicyAnnotations = map[string]string{waveAnnotationKey: strconv.Itoa(lowestWave)}
+		if len(waves) > 1 {
+			options.report.WarnfAt(position, "policy %s: manifests have different ztp-deploy-waves %v, the policy wave is set to the lowest one, %d. Use -%s to generate one policy per wave",
+				newPolicy.Name, waves, lowestWave, splitWavesFlag)
+		}
+		return []acmformat.PolicyConfig{*newPolicy}
+	}
+
+	var policyNames []string
+	for _, wave := range waves {
+		wavePolicy := *newPolicy
+		wavePolicy.Manifests = nil
+		if wave != lowestWave {
+			wavePolicy.Name = fmt.Sprintf("%s-wave-%d", newPolicy.Name, wave)
+		}
+		wavePolicy.PolicyAnnotations = map[string]string{waveAnnotationKey: strconv.Itoa(wave)}
+		for manifestIndex := range newPolicy.Manifests {
+			manifestWave, found := manifestWaves[manifestIndex]
+			if !found {
+				manifestWave = lowestWave
+			}
+			if manifestWave == wave {
+				wavePolicy.Manifests = append(wavePolicy.Manifests, newPolicy.Manifests[manifestIndex])
+			}
 		}
-		if len(policyGenTemp.Spec.SourceFiles[srcFileIndex].Status) != 0 {
+		newPolicies = append(newPolicies, wavePolicy)
+		policyNames = append(policyNames, fmt.Sprintf("%s (wave %d)", wavePolicy.Name, wave))
+	}
+	options.report.InfofAt(position, "policy %s: manifests have different ztp-deploy-waves %v, split into the policies %s",
+		newPolicy.Name, waves, strings.Join(policyNames, ", "))
+	return newPolicies
+}
+
+// Builds the Kustomize patch corresponding to the overrides of a PGT source file
+func convertSourceFileToPatch(sourceFile *pgtformat.SourceFile) (newPatch map[string]interface{}, hasPatch bool) {
+	newPatch = make(map[string]interface{})
+	sections := []struct {
+		key     string
+		content map[string]interface{}
+	}{
+		{"metadata", metadataWithoutJSONPatches(sourceFile.Metadata)},
+		{"spec", sourceFile.Spec},
+		{"status", sourceFile.Status},
+		{"data", sourceFile.Data},
+		{"binaryData", sourceFile.BinaryData},
+	}
+	for _, section := range sections {
+		if len(section.content) != 0 {
 			hasPatch = true
-			newPatch["status"] = policyGenTemp.Spec.SourceFiles[srcFileIndex].Status
+			newPatch[section.key] = section.content
 		}
-		if hasPatch {
-			newManifest.Patches = append(newManifest.Patches, newPatch)
+	}
+	return newPatch, hasPatch
+}
+
+// Reports invalid base64 values in the binaryData overrides of a source file, and flags the Secrets
+// whose content would end up in the converted templates
+func checkSourceFileData(options *conversionOptions, inputFile, sourceCRPath string, sourceFile *pgtformat.SourceFile) {
+	for key, value := range sourceFile.BinaryData {
+		if !isBase64String(value) {
+			options.report.WarnfAt(sourceFilePosition(options, inputFile, sourceFile, "binaryData."+key), "source file %s: binaryData.%s is not a valid base64 string", sourceFile.FileName, key)
+		}
+	}
+
+	kind, err := fileutils.GetManifestKind(sourceCRPath)
+	if err != nil || kind.Kind != "Secret" {
+		return
+	}
+	for key, value := range sourceFile.Data {
+		if !isBase64String(value) {
+			options.report.WarnfAt(sourceFilePosition(options, inputFile, sourceFile, "data."+key), "source file %s: data.%s is not a valid base64 string", sourceFile.FileName, key)
 		}
+	}
+	if len(sourceFile.Data) != 0 || len(sourceFile.BinaryData) != 0 {
+		options.report.WarnfAt(sourceFilePosition(options, inputFile, sourceFile, ""), "source file %s: Secret data is only base64 encoded in the converted template, "+
+			"do not commit it as is, use hub templates or an external secret store instead", sourceFile.FileName)
+	}
+}
 
-		pathRelativeToOutputDir := filepath.Join(outputDir, newManifest.Path)
+func isBase64String(value interface{}) bool {
+	stringValue, ok := value.(string)
+	if !ok {
+		return false
+	}
+	_, err := base64.StdEncoding.DecodeString(stringValue)
+	return err == nil
+}
 
-		var ok bool
-		annotations, err := fileutils.GetAnnotationsOnly(pathRelativeToOutputDir)
-		if err != nil {
-			fmt.Printf("could not get annotations from manifest:%s, err: %s\n", policyGenTemp.Spec.SourceFiles[srcFileIndex].FileName, err)
+// Moves the ComplianceType and RemediationAction set identically on every manifest of a policy to the
+// policy level. If manifests disagree, they are not consolidated, since ACM requires all the
+// manifests of a consolidated ConfigurationPolicy to share the same options
+func hoistManifestConfigurationPolicyOptions(newPolicy *acmformat.PolicyConfig) {
+	if len(newPolicy.Manifests) == 0 {
+		return
+	}
+	complianceTypes := map[string]bool{}
+	remediationActions := map[string]bool{}
+	for manifestIndex := range newPolicy.Manifests {
+		complianceTypes[newPolicy.Manifests[manifestIndex].ComplianceType] = true
+		remediationActions[newPolicy.Manifests[manifestIndex].RemediationAction] = true
+	}
+
+	if len(complianceTypes) > 1 || len(remediationActions) > 1 {
+		consolidateManifests := false
+		newPolicy.ConsolidateManifests = &consolidateManifests
+	}
+
+	if len(complianceTypes) == 1 {
+		newPolicy.ComplianceType = newPolicy.Manifests[0].ComplianceType
+		for manifestIndex := range newPolicy.Manifests {
+			newPolicy.Manifests[manifestIndex].ComplianceType = ""
 		}
-		if wave, ok = annotations.Metadata.Annotations[waveAnnotationKey]; err == nil && ok &&
-			wave != "" &&
-			stringhelper.IsNumber(wave) {
-			newPolicy.PolicyAnnotations[waveAnnotationKey] = wave
+	}
+
+	if len(remediationActions) == 1 {
+		newPolicy.RemediationAction = newPolicy.Manifests[0].RemediationAction
+		for manifestIndex := range newPolicy.Manifests {
+			newPolicy.Manifests[manifestIndex].RemediationAction = ""
 		}
-		newPolicy.Manifests = append(newPolicy.Manifests, newManifest)
 	}
-	return newPolicy
 }
 
 // Maps miscellaneous PGT fields to the ACM Gen fields
 func convertSimpleMiscellaneousFields(policyGenTemp *pgtformat.PolicyGenTemplate, acmGenTempConversion *acmformat.AcmGenTemplate, rootName string) {
 	acmGenTempConversion.PolicyDefaults.Namespace = policyGenTemp.Metadata.Namespace
-	acmGenTempConversion.PolicyDefaults.RemediationAction = "inform"
+	acmGenTempConversion.PolicyDefaults.RemediationAction = policyGenTemp.Spec.RemediationAction
+	acmGenTempConversion.PolicyDefaults.ComplianceType = policyGenTemp.Spec.ComplianceType
 	acmGenTempConversion.Kind = "PolicyGenerator"
 	acmGenTempConversion.APIVersion = "policy.open-cluster-management.io/v1"
 
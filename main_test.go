@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPreRenderingDecision(t *testing.T) {
+	configMap := map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "config"}}
+	ptpConfig := map[string]interface{}{"apiVersion": "ptp.openshift.io/v1", "kind": "PtpConfig", "metadata": map[string]interface{}{"name": "slave"}}
+	pod := map[string]interface{}{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]interface{}{"name": "pod"}}
+	configMapPatch := map[string]interface{}{"data": map[string]interface{}{"key": "value"}}
+	podPatch := map[string]interface{}{"spec": map[string]interface{}{
+		"tolerations": []interface{}{map[string]interface{}{"key": "node-role.kubernetes.io/master", "effect": "NoSchedule"}},
+	}}
+
+	tests := []struct {
+		name       string
+		kinds      []string
+		manifest   map[string]interface{}
+		patch      map[string]interface{}
+		wantNeeded bool
+		wantReason string
+	}{
+		{
+			name:     "listed kind",
+			kinds:    []string{"PtpConfig"},
+			manifest: ptpConfig, patch: configMapPatch,
+			wantNeeded: true,
+		},
+		{
+			name:     "kind not listed",
+			kinds:    []string{"PtpConfig"},
+			manifest: configMap, patch: configMapPatch,
+		},
+		{
+			name:     "auto, built-in kind",
+			kinds:    []string{autoPreRenderKinds},
+			manifest: configMap, patch: configMapPatch,
+			wantReason: "built-in kind, Kustomize merges the patches with its built-in schema",
+		},
+		{
+			name:     "auto, custom resource",
+			kinds:    []string{autoPreRenderKinds},
+			manifest: ptpConfig, patch: configMapPatch,
+			wantNeeded: true,
+			wantReason: "ptp.openshift.io/v1 PtpConfig is a custom resource without built-in strategic merge metadata",
+		},
+		{
+			name:     "auto, built-in kind with a list without merge key",
+			kinds:    []string{autoPreRenderKinds},
+			manifest: pod, patch: podPatch,
+			wantNeeded: true,
+			wantReason: "the patch contains lists of objects without merge key in the v1 Pod built-in schema: spec.tolerations",
+		},
+		{
+			name:     "auto with a listed built-in kind",
+			kinds:    []string{autoPreRenderKinds, "ConfigMap"},
+			manifest: configMap, patch: configMapPatch,
+			wantNeeded: true,
+			wantReason: "the kind ConfigMap is listed with -k",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := &conversionOptions{preRenderPatchKindList: test.kinds}
+			needed, reason := preRenderingDecision(options, []map[string]interface{}{test.manifest}, []map[string]interface{}{test.patch})
+			if needed != test.wantNeeded || reason != test.wantReason {
+				t.Errorf("preRenderingDecision() = %t, %q, want %t, %q", needed, reason, test.wantNeeded, test.wantReason)
+			}
+		})
+	}
+}
+
+func TestParseClusterSets(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "global", want: []string{"global"}},
+		{value: " global, canary ", want: []string{"global", "canary"}},
+		{value: "a,,b,", want: []string{"a", "b"}},
+		{value: "", wantErr: true},
+		{value: " , ", wantErr: true},
+	}
+	for _, test := range tests {
+		clusterSets, err := parseClusterSets(test.value)
+		if (err != nil) != test.wantErr || !reflect.DeepEqual(clusterSets, test.want) {
+			t.Errorf("parseClusterSets(%q) = %v, %v, want %v, error %t", test.value, clusterSets, err, test.want, test.wantErr)
+		}
+	}
+}
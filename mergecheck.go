@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/test-network-function/pgt2acm/packages/patches"
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"github.com/test-network-function/pgt2acm/packages/pgtmerge"
+)
+
+const checkMergeFlag = "check-merge"
+
+// Compares, for every source file of a PGT with overrides, the source CR merged the way PGT does with
+// the source CR patched the way the converted template is: with Kustomize and the schema if the patches
+// are pre-rendered or the PGT has wrapInPolicy set to false, otherwise with the Kustomize built-in schema
+// only as the ACM policy generator does. The patches are compared as written in the PGT, without the -fix
+// rewrites. Each field that differs is reported with its JSON path, and the source files with JSON
+// patches, which PGT cannot apply, are reported as not compared
+func checkMergeEquivalence(options *conversionOptions, inputFile string, policyGenTemp *pgtformat.PolicyGenTemplate) {
+	for srcFileIndex := range policyGenTemp.Spec.SourceFiles {
+		sourceFile := &policyGenTemp.Spec.SourceFiles[srcFileIndex]
+		sourceCRPath := filepath.Join(options.outputDir, sourceCrPrefix, sourceFile.FileName)
+		if hasJSONPatches(sourceFile) {
+			options.report.InfofAt(sourceFilePosition(options, inputFile, sourceFile, ""), "source file %s: the PGT and Kustomize merges are not compared, PGT has no equivalent of the JSON patches", sourceFile.FileName)
+			continue
+		}
+		override, hasOverride := convertSourceFileToPatch(sourceFile)
+		if !hasOverride || isStatusCheck(sourceCRPath, sourceFile) {
+			continue
+		}
+		err := checkSourceFileMerge(options, inputFile, policyGenTemp, sourceFile, override)
+		if err != nil {
+			options.report.WarnfAt(sourceFilePosition(options, inputFile, sourceFile, ""), "source file %s: could not compare the PGT and Kustomize merges, err: %s", sourceFile.FileName, err)
+		}
+	}
+}
+
+func checkSourceFileMerge(options *conversionOptions, inputFile string, policyGenTemp *pgtformat.PolicyGenTemplate,
+	sourceFile *pgtformat.SourceFile, override map[string]interface{}) (err error) {
+	renderedPath, err := renderMCPLines(options, filepath.Join(options.outputDir, sourceCrPrefix, sourceFile.FileName), policyGenTemp.Spec.Mcp)
+	if err != nil {
+		return err
+	}
+	manifests, err := patches.UnmarshalManifestFile(renderedPath)
+	if err != nil {
+		return err
+	}
+	if len(manifests) == 0 {
+		return fmt.Errorf("found empty YAML in the manifest at %s", renderedPath)
+	}
+	pgtMerged := mergeSourceFileOverrides(manifests, sourceFile, override)
+
+	// Validate and ApplyPatches modify their inputs
+	patch := pgtmerge.DeepCopy(override)
+	patcher := patches.ManifestPatcher{Manifests: manifests, Patches: []map[string]interface{}{patch}}
+	kustomizeName := "the Kustomize resources patch"
+	if policyGenTemp.Spec.WrapInPolicy {
+		preRendered, _ := preRenderingDecision(options, manifests, patcher.Patches)
+		patcher.BuiltInSchemaOnly = !preRendered
+		kustomizeName = "the ACM policy generator"
+		if preRendered {
+			kustomizeName = "the pre-rendered Kustomize patch"
+		}
+		if options.replaceSchemaless {
+			_, err = patcher.InsertReplaceDirectives(options.schema)
+			if err != nil {
+				return err
+			}
+		}
+	} else if len(manifests) == 1 {
+		renameSourceCR(manifests[0], patch)
+	}
+	err = patcher.Validate()
+	if err != nil {
+		return err
+	}
+	kustomizeMerged, err := patcher.ApplyPatches(options.schema)
+	if err != nil {
+		return err
+	}
+
+	kustomizeByID := map[string]map[string]interface{}{}
+	for _, manifest := range kustomizeMerged {
+		kustomizeByID[manifestID(manifest)] = manifest
+	}
+	for _, pgtManifest := range pgtMerged {
+		id := manifestID(pgtManifest)
+		kustomizeManifest, ok := kustomizeByID[id]
+		if !ok {
+			options.report.WarnfAt(sourceFilePosition(options, inputFile, sourceFile, ""), "source file %s: %s is generated by PGT but not by %s", sourceFile.FileName, id, kustomizeName)
+			continue
+		}
+		for _, difference := range pgtmerge.Diff(pgtManifest, kustomizeManifest) {
+			if placeholder, ok := difference.Right.(string); ok && !difference.InLeft && strings.HasPrefix(placeholder, "$") {
+				options.report.WarnfAt(sourceFilePosition(options, inputFile, sourceFile, difference.Path), "source file %s: %s %s is left unset, PGT removes the %s placeholder but %s keeps it. Set the field in the source file or remove it from the source CR",
+					sourceFile.FileName, id, difference.Path, placeholder, kustomizeName)
+				continue
+			}
+			options.report.WarnfAt(sourceFilePosition(options, inputFile, sourceFile, difference.Path), "source file %s: %s %s differs, PGT: %s, %s: %s", sourceFile.FileName, id, difference.Path,
+				formatMergedValue(difference.Left, difference.InLeft), kustomizeName, formatMergedValue(difference.Right, difference.InRight))
+		}
+	}
+	return nil
+}
+
+// Returns the kind/namespace/name identifying a manifest
+func manifestID(manifest map[string]interface{}) string {
+	kind, _ := manifest["kind"].(string)
+	metadata, _ := manifest["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+	if namespace == "" {
+		return kind + "/" + name
+	}
+	return kind + "/" + namespace + "/" + name
+}
+
+func formatMergedValue(value interface{}, present bool) string {
+	if !present {
+		return "<absent>"
+	}
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(content)
+}
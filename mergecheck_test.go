@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/test-network-function/pgt2acm/packages/patches"
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"github.com/test-network-function/pgt2acm/packages/report"
+)
+
+// A PtpConfig source CR with placeholders, some overridden by the PGT and $phc2sysOpts left unset
+const placeholderPtpConfig = `apiVersion: ptp.openshift.io/v1
+kind: PtpConfig
+metadata:
+  name: slave
+  namespace: openshift-ptp
+spec:
+  profile:
+    - name: "$name"
+      interface: "$interface"
+      phc2sysOpts: "$phc2sysOpts"
+      ptp4lOpts: "-2 -s"
+`
+
+func TestCheckMergeEquivalence(t *testing.T) {
+	outputDir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(outputDir, sourceCrPrefix), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(outputDir, sourceCrPrefix, "PtpConfigSlave.yaml"), []byte(placeholderPtpConfig), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policyGenTemp := &pgtformat.PolicyGenTemplate{Spec: pgtformat.PolicyGenTempSpec{
+		Mcp:          "worker",
+		WrapInPolicy: true,
+		SourceFiles: []pgtformat.SourceFile{{
+			FileName:   "PtpConfigSlave.yaml",
+			PolicyName: "config-policy",
+			Spec: map[string]interface{}{
+				"profile": []interface{}{map[string]interface{}{"name": "slave", "interface": "ens5f0"}},
+			},
+		}},
+	}}
+	options := &conversionOptions{outputDir: outputDir, report: &report.Report{}, renderedSourceCRs: map[string]string{}}
+
+	checkMergeEquivalence(options, "pgt.yaml", policyGenTemp)
+
+	// The ACM policy generator replaces the profile list, which PGT merges item by item. The unset
+	// $phc2sysOpts placeholder is removed by PGT and dropped with the list by Kustomize, no difference
+	want := []report.Entry{{
+		Level: report.LevelWarning,
+		File:  "pgt.yaml",
+		Message: `source file PtpConfigSlave.yaml: PtpConfig/openshift-ptp/slave $.spec.profile[0].ptp4lOpts differs, ` +
+			`PGT: "-2 -s", the ACM policy generator: <absent>`,
+	}}
+	if len(options.report.Entries) != len(want) {
+		t.Fatalf("report entries = %v, want %v", options.report.Entries, want)
+	}
+	for index := range want {
+		if options.report.Entries[index] != want[index] {
+			t.Errorf("report entry %d = %v, want %v", index, options.report.Entries[index], want[index])
+		}
+	}
+}
+
+func TestCheckMergeEquivalenceIgnoresFix(t *testing.T) {
+	outputDir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(outputDir, sourceCrPrefix), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(outputDir, sourceCrPrefix, "PtpConfigSlave.yaml"), []byte(placeholderPtpConfig), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policyGenTemp := &pgtformat.PolicyGenTemplate{Spec: pgtformat.PolicyGenTempSpec{
+		WrapInPolicy: true,
+		SourceFiles: []pgtformat.SourceFile{
+			{
+				FileName:   "PtpConfigSlave.yaml",
+				PolicyName: "config-policy",
+				Metadata:   map[string]interface{}{"name": "slav", "namespace": "openshift-ptp"},
+				Spec:       map[string]interface{}{"profile": []interface{}{map[string]interface{}{"name": "slave", "interface": "ens5f0"}}},
+			},
+			{
+				FileName:   "ConfigMapGeneric.yaml",
+				PolicyName: "config-policy",
+				Metadata:   map[string]interface{}{"annotations": map[string]interface{}{jsonPatchAnnotation: `[{"op": "remove", "path": "/data/setting"}]`}},
+			},
+		},
+	}}
+	options := &conversionOptions{outputDir: outputDir, report: &report.Report{}, renderedSourceCRs: map[string]string{}, fix: fixPatch}
+
+	checkMergeEquivalence(options, "pgt.yaml", policyGenTemp)
+
+	// The patch matching no resource is compared as written in the PGT, the fix is left to the conversion
+	if len(options.report.Entries) != 2 {
+		t.Fatalf("report entries = %v, want 2 entries", options.report.Entries)
+	}
+	wantPrefix := "source file PtpConfigSlave.yaml: could not compare the PGT and Kustomize merges, err: "
+	if entry := options.report.Entries[0]; entry.Level != report.LevelWarning || !strings.HasPrefix(entry.Message, wantPrefix) {
+		t.Errorf("report entry 0 = %v, want a warning starting with %q", entry, wantPrefix)
+	}
+	want := "source file ConfigMapGeneric.yaml: the PGT and Kustomize merges are not compared, PGT has no equivalent of the JSON patches"
+	if entry := options.report.Entries[1]; entry.Level != report.LevelInfo || entry.Message != want {
+		t.Errorf("report entry 1 = %v, want the info %q", entry, want)
+	}
+	if name := patches.StringField(policyGenTemp.Spec.SourceFiles[0].Metadata, "name"); name != "slav" {
+		t.Errorf("source file metadata.name = %s, want slav", name)
+	}
+}
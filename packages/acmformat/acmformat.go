@@ -32,7 +32,7 @@ type PolicyOptions struct {
 	ExtraDependencies              []PolicyDependency `json:"extraDependencies,omitempty" yaml:"extraDependencies,omitempty"`
 	Placement                      PlacementConfig    `json:"placement,omitempty" yaml:"placement,omitempty"`
 	Standards                      []string           `json:"standards,omitempty" yaml:"standards,omitempty"`
-	ConsolidateManifests           bool               `json:"consolidateManifests,omitempty" yaml:"consolidateManifests,omitempty"`
+	ConsolidateManifests           *bool              `json:"consolidateManifests,omitempty" yaml:"consolidateManifests,omitempty"`
 	OrderManifests                 *bool              `json:"orderManifests" yaml:"orderManifests,omitempty"`
 	Disabled                       bool               `json:"disabled,omitempty" yaml:"disabled,omitempty"`
 	IgnorePending                  bool               `json:"ignorePending,omitempty" yaml:"ignorePending,omitempty"`
@@ -67,6 +67,8 @@ type Manifest struct {
 	Patches                    []map[string]interface{} `json:"patches,omitempty" yaml:"patches,omitempty"`
 	ExtraDependencies          []PolicyDependency       `json:"extraDependencies,omitempty" yaml:"extraDependencies,omitempty"`
 	IgnorePending              bool                     `json:"ignorePending,omitempty" yaml:"ignorePending,omitempty"`
+	// The index of the PGT source file the manifest is converted from, not part of the template
+	SourceFileIndex int `json:"-" yaml:"-"`
 }
 
 type NamespaceSelector struct {
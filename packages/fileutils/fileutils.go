@@ -1,12 +1,15 @@
 package fileutils
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v2"
@@ -125,6 +128,26 @@ func GetManifestKind(filePath string) (kindType KindType, err error) {
 	return kindType, nil
 }
 
+// Gets the kinds of every document in a multi-document manifest file
+func GetManifestKinds(filePath string) (kindTypes []KindType, err error) {
+	yamlFile, err := os.ReadFile(filePath)
+	if err != nil {
+		return kindTypes, fmt.Errorf("could not read %s: %s", filePath, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(yamlFile))
+	for {
+		kindType := KindType{}
+		err = decoder.Decode(&kindType)
+		if errors.Is(err, io.EOF) {
+			return kindTypes, nil
+		}
+		if err != nil {
+			return kindTypes, fmt.Errorf("could not parse %s as yaml: %s", filePath, err)
+		}
+		kindTypes = append(kindTypes, kindType)
+	}
+}
+
 type AnnotationsOnly struct {
 	Metadata MetaData `yaml:"metadata"`
 }
@@ -146,55 +169,117 @@ func GetAnnotationsOnly(filePath string) (annotations AnnotationsOnly, err error
 	return annotations, nil
 }
 
-const defaultPlacementBindings = `
----
-apiVersion: cluster.open-cluster-management.io/v1beta2
-kind: ManagedClusterSetBinding
-metadata:
-  name: global
-  namespace: ztp-common
-spec:
-  clusterSet: global
----
-apiVersion: cluster.open-cluster-management.io/v1beta2
-kind: ManagedClusterSetBinding
-metadata:
-  name: global
-  namespace: ztp-group
-spec:
-  clusterSet: global
----
-apiVersion: cluster.open-cluster-management.io/v1beta2
-kind: ManagedClusterSetBinding
-metadata:
-  name: global
-  namespace: ztp-site
-spec:
-  clusterSet: global
-`
-
-func AddDefaultPlacementBindingsToNSFile(namespaceFilePath, outputDir string) (err error) {
+const (
+	// The cluster set bound to the policy namespaces by default
+	DefaultClusterSet               = "global"
+	managedClusterSetBindingKind    = "ManagedClusterSetBinding"
+	managedClusterSetBindingVersion = "cluster.open-cluster-management.io/v1beta2"
+)
+
+// A ManagedClusterSetBinding, binding a cluster set to the namespace of the policies and placements
+type ManagedClusterSetBinding struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec struct {
+		ClusterSet string `yaml:"clusterSet"`
+	} `yaml:"spec"`
+}
+
+// Adds a ManagedClusterSetBinding of each cluster set to each policy namespace to the ns.yaml file of the
+// output directory. The documents of the file are parsed so that the existing bindings are not added again
+func AddDefaultPlacementBindingsToNSFile(namespaceFilePath, outputDir string, namespaces, clusterSets []string) (err error) {
 	fullNamespaceFilePath := filepath.Join(outputDir, namespaceFilePath)
 	fileContent, err := os.ReadFile(fullNamespaceFilePath)
 	if err != nil {
 		return fmt.Errorf("could not read %s: %s", fullNamespaceFilePath, err)
 	}
 
-	fileContent = []byte(string(fileContent) + defaultPlacementBindings)
+	existingBindings, err := clusterSetBindings(fileContent)
+	if err != nil {
+		return fmt.Errorf("could not parse %s as yaml: %s", fullNamespaceFilePath, err)
+	}
+	var addedBindings []string
+	for _, namespace := range namespaces {
+		for _, clusterSet := range clusterSets {
+			if existingBindings[namespace+"/"+clusterSet] {
+				continue
+			}
+			existingBindings[namespace+"/"+clusterSet] = true
+			binding := ManagedClusterSetBinding{APIVersion: managedClusterSetBindingVersion, Kind: managedClusterSetBindingKind}
+			binding.Metadata.Name = clusterSet
+			binding.Metadata.Namespace = namespace
+			binding.Spec.ClusterSet = clusterSet
+			var bindingYAML []byte
+			bindingYAML, err = yaml.Marshal(&binding)
+			if err != nil {
+				return fmt.Errorf("could not marshall ManagedClusterSetBinding, err: %s", err)
+			}
+			fileContent = append(bytes.TrimRight(fileContent, "\n"), []byte("\n---\n")...)
+			fileContent = append(fileContent, bindingYAML...)
+			addedBindings = append(addedBindings, namespace+"/"+clusterSet)
+		}
+	}
+	if len(addedBindings) == 0 {
+		fmt.Printf("Placement bindings already in:%s\n", fullNamespaceFilePath)
+		return nil
+	}
+
 	err = os.WriteFile(fullNamespaceFilePath, fileContent, DefaultFileWritePermissions)
 	if err != nil {
 		return fmt.Errorf("error writing to file: %s, err: %s", fullNamespaceFilePath, err)
 	}
-	fmt.Printf("Added default placement binding to:%s\n", fullNamespaceFilePath)
+	fmt.Printf("Added placement bindings %s to:%s\n", strings.Join(addedBindings, ", "), fullNamespaceFilePath)
 	return nil
 }
 
+// Returns the namespace/clusterSet pairs of the ManagedClusterSetBindings of a multi-document YAML file
+func clusterSetBindings(content []byte) (bindings map[string]bool, err error) {
+	bindings = map[string]bool{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		binding := ManagedClusterSetBinding{}
+		err = decoder.Decode(&binding)
+		if errors.Is(err, io.EOF) {
+			return bindings, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if binding.Kind != managedClusterSetBindingKind {
+			continue
+		}
+		// The name of a binding is the name of its cluster set
+		clusterSet := binding.Spec.ClusterSet
+		if clusterSet == "" {
+			clusterSet = binding.Metadata.Name
+		}
+		bindings[binding.Metadata.Namespace+"/"+clusterSet] = true
+	}
+}
+
 type Kustomization struct {
 	Generators []string `yaml:"generators"`
 	Resources  []string `yaml:"resources"`
 }
 
-func RenameACMGenTemplatesInKustomization(inputFile, outputDir string) (err error) {
+// Files generated from a converted PGT file, relative to the output directory
+type ConvertedFiles struct {
+	// ACM Gen templates, listed as generators
+	Generators []string
+	// Directories of plain resources generated from PGTs not wrapped in policies, listed as resources
+	Resources []string
+	// Namespaces of the policies of the ACM Gen templates, bound to the cluster sets in ns.yaml
+	Namespaces []string
+}
+
+// Rewrites the generators of the input kustomization.yaml to point to the converted ACM Gen templates.
+// convertedGenerators maps each converted PGT file, relative to the input directory, to the files
+// generated from it. Generators that were not converted are only prefixed.
+func RenameACMGenTemplatesInKustomization(inputFile, outputDir string, convertedGenerators map[string]ConvertedFiles) (err error) {
 	inputKustomization := filepath.Join(inputFile, KustomizationFileName)
 	fileContent, err := os.ReadFile(inputKustomization)
 	if err != nil {
@@ -209,6 +294,11 @@ func RenameACMGenTemplatesInKustomization(inputFile, outputDir string) (err erro
 	}
 	updatedKustomization := Kustomization{}
 	for _, g := range kustomization.Generators {
+		if outputFiles, ok := convertedGenerators[filepath.Clean(g)]; ok {
+			updatedKustomization.Generators = append(updatedKustomization.Generators, outputFiles.Generators...)
+			updatedKustomization.Resources = append(updatedKustomization.Resources, outputFiles.Resources...)
+			continue
+		}
 		updatedKustomization.Generators = append(updatedKustomization.Generators, PrefixLastPathComponent(g, ACMPrefix))
 	}
 	// Copy all resources to destination directory
@@ -234,8 +324,10 @@ func RenameACMGenTemplatesInKustomization(inputFile, outputDir string) (err erro
 	return nil
 }
 
-func CopyAndProcessNSAndKustomizationYAML(nsFilePath, inputFile, outputDir string, skipUpdateNs bool) (err error) {
-	err = RenameACMGenTemplatesInKustomization(inputFile, outputDir)
+// Rewrites the kustomization.yaml generators and binds the cluster sets to the namespaces of the converted
+// policies in ns.yaml, unless skipUpdateNs is set
+func CopyAndProcessNSAndKustomizationYAML(nsFilePath, inputFile, outputDir string, skipUpdateNs bool, convertedGenerators map[string]ConvertedFiles, clusterSets []string) (err error) {
+	err = RenameACMGenTemplatesInKustomization(inputFile, outputDir, convertedGenerators)
 	if err != nil {
 		return fmt.Errorf("could not rename generators in kustomization file, err: %s", err)
 	}
@@ -243,13 +335,28 @@ func CopyAndProcessNSAndKustomizationYAML(nsFilePath, inputFile, outputDir strin
 	if skipUpdateNs {
 		return nil
 	}
-	err = AddDefaultPlacementBindingsToNSFile(nsFilePath, outputDir)
+	err = AddDefaultPlacementBindingsToNSFile(nsFilePath, outputDir, policyNamespaces(convertedGenerators), clusterSets)
 	if err != nil {
 		return fmt.Errorf("could not add placement bindings in NS file file, err: %s", err)
 	}
 	return nil
 }
 
+// Returns the sorted namespaces of the policies of all the converted PGT files, each namespace once
+func policyNamespaces(convertedGenerators map[string]ConvertedFiles) (namespaces []string) {
+	seen := map[string]bool{}
+	for _, outputFiles := range convertedGenerators {
+		for _, namespace := range outputFiles.Namespaces {
+			if namespace != "" && !seen[namespace] {
+				seen[namespace] = true
+				namespaces = append(namespaces, namespace)
+			}
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
 // function found at https://opensource.com/article/18/6/copying-files-go
 func Copy(src, dst string) (int64, error) {
 	dstDir := filepath.Dir(dst)
@@ -3,6 +3,9 @@ package labels
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 
 	yamlconv "github.com/ghodss/yaml"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -65,3 +68,47 @@ func OutputGeneric(selector labels.Selector) (output map[string]interface{}, err
 	}
 	return output, nil
 }
+
+// Filters a label or annotation map by key. The filter is a list of patterns where "*" matches any
+// string. A key is kept if it matches at least one pattern and no pattern prefixed with "!".
+// The keys that were filtered out are returned sorted
+func FilterKeys(keyValues map[string]string, filter []string) (filtered map[string]string, excluded []string) {
+	var includes, excludes []*regexp.Regexp
+	for _, pattern := range filter {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if strings.HasPrefix(pattern, "!") {
+			excludes = append(excludes, keyPatternToRegexp(strings.TrimPrefix(pattern, "!")))
+			continue
+		}
+		includes = append(includes, keyPatternToRegexp(pattern))
+	}
+
+	for key, value := range keyValues {
+		if !matchesAny(key, includes) || matchesAny(key, excludes) {
+			excluded = append(excluded, key)
+			continue
+		}
+		if filtered == nil {
+			filtered = map[string]string{}
+		}
+		filtered[key] = value
+	}
+	sort.Strings(excluded)
+	return filtered, excluded
+}
+
+func keyPatternToRegexp(pattern string) *regexp.Regexp {
+	return regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$")
+}
+
+func matchesAny(key string, patterns []*regexp.Regexp) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package patches
+
+import (
+	"fmt"
+	"sort"
+
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	patchDirectiveKey = "$patch"
+	replaceDirective  = "replace"
+)
+
+// InsertReplaceDirectives adds a $patch: replace directive to the maps of the patches whose field has no
+// structural schema, such as the PtpConfig plugins, in the schema used by ApplyPatches. Kustomize
+// merges such maps key by key, keeping the keys of the manifest that the patch omits. The lists without
+// schema are already replaced by Kustomize, which does not remove the directive from lists, so they are
+// left unchanged, as are the contents of the lists without merge key. Returns the paths of the fields
+// where a directive was added
+func (m *ManifestPatcher) InsertReplaceDirectives(schema string) (fieldPaths []string, err error) {
+	schemaID, schemaJSON, err := m.selectSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	err = loadSchema(schemaID, schemaJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, patch := range m.Patches {
+		apiVersion, _ := patch["apiVersion"].(string)
+		kind, _ := patch["kind"].(string)
+		if (apiVersion == "" || kind == "") && len(m.Manifests) == 1 {
+			apiVersion, _ = m.Manifests[0]["apiVersion"].(string)
+			kind, _ = m.Manifests[0]["kind"].(string)
+		}
+		resourceSchema := openapi.SchemaForResourceType(kyaml.TypeMeta{APIVersion: apiVersion, Kind: kind})
+		if resourceSchema == nil {
+			// Nothing is structural without schema, the patch is merged as is
+			continue
+		}
+		for _, key := range sortedPatchKeys(patch) {
+			if key == "apiVersion" || key == "kind" || key == "metadata" {
+				continue
+			}
+			fieldPaths = append(fieldPaths, insertFieldDirectives(patch, key, resourceSchema.Field(key), key)...)
+		}
+	}
+	return fieldPaths, nil
+}
+
+// Adds the directives to a field of a patch map and its content
+func insertFieldDirectives(parent map[string]interface{}, key string, schema *openapi.ResourceSchema, fieldPath string) (fieldPaths []string) {
+	switch value := parent[key].(type) {
+	case map[string]interface{}:
+		if isSchemaless(schema) {
+			if _, ok := value[patchDirectiveKey]; ok {
+				return nil
+			}
+			value[patchDirectiveKey] = replaceDirective
+			return []string{fieldPath}
+		}
+		for _, childKey := range sortedPatchKeys(value) {
+			fieldPaths = append(fieldPaths, insertFieldDirectives(value, childKey, schema.Field(childKey), joinFieldPath(fieldPath, childKey))...)
+		}
+	case []interface{}:
+		if isSchemaless(schema) {
+			return nil
+		}
+		if _, mergeKey := schema.PatchStrategyAndKey(); mergeKey == "" {
+			return nil
+		}
+		elementsSchema := schema.Elements()
+		for index, item := range value {
+			itemMap, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, childKey := range sortedPatchKeys(itemMap) {
+				var fieldSchema *openapi.ResourceSchema
+				if elementsSchema != nil {
+					fieldSchema = elementsSchema.Field(childKey)
+				}
+				fieldPaths = append(fieldPaths, insertFieldDirectives(itemMap, childKey, fieldSchema, fmt.Sprintf("%s[%d].%s", fieldPath, index, childKey))...)
+			}
+		}
+	}
+	return fieldPaths
+}
+
+// Returns true if a map or list field has no structural schema: no schema at all, or an object schema
+// without properties whose values are not described either
+func isSchemaless(schema *openapi.ResourceSchema) bool {
+	if schema.IsMissingOrNull() {
+		return true
+	}
+	if len(schema.Schema.Properties) != 0 {
+		return false
+	}
+	if schema.Schema.Type.Contains("array") {
+		return schema.Elements() == nil
+	}
+	if schema.Schema.AdditionalProperties != nil && schema.Schema.AdditionalProperties.Schema != nil {
+		valueSchema := schema.Schema.AdditionalProperties.Schema
+		return len(valueSchema.Type) == 0 && len(valueSchema.Properties) == 0 && valueSchema.Ref.String() == ""
+	}
+	return true
+}
+
+// Returns the keys of a patch map without the directives
+func sortedPatchKeys(content map[string]interface{}) (keys []string) {
+	for key := range content {
+		if key != patchDirectiveKey {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
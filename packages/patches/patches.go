@@ -6,13 +6,15 @@ package patches
 import (
 	"errors"
 	"fmt"
-	"os"
 	"path"
+	"strings"
 
+	"github.com/test-network-function/pgt2acm/packages/schemas"
 	yaml "gopkg.in/yaml.v3"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/kustomize/api/krusty"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
 )
 
 type ManifestPatcher struct {
@@ -22,7 +24,21 @@ type ManifestPatcher struct {
 	// to the input maps. If this is an issue, provide a deep copy of the patches.
 	Patches []map[string]interface{}
 
+	// The JSON 6902 patches to apply on the manifests, after the Kustomize patches
+	JSONPatches []JSONPatch
+
 	OpenAPI []map[string]interface{}
+	// Applies the patches with the Kustomize built-in schema only, ignoring the schema library and the
+	// schema passed to ApplyPatches, as the ACM policy generator does
+	BuiltInSchemaOnly bool
+}
+
+// JSONPatch is a list of JSON 6902 operations applied on the manifest selected by the target
+type JSONPatch struct {
+	// The manifest to patch. When there is a single manifest, the missing fields are set from the manifest
+	Target PatchTarget
+	// The JSON 6902 operations, such as {"op": "remove", "path": "/spec/profile/1"}
+	Operations []map[string]interface{}
 }
 
 // validateManifestInfo verifies that the apiVersion, kind, metadata.name fields from a manifest
@@ -70,12 +86,19 @@ func (m *ManifestPatcher) Validate() error {
 			`when there is more than one manifest it can apply to`
 
 		for _, patch := range m.Patches {
+			setPatchIdentity(m.Manifests, patch)
 			err := validateManifestInfo(patch, patchErrTemplate)
 			if err != nil {
 				return err
 			}
 		}
 
+		for i := range m.JSONPatches {
+			if m.JSONPatches[i].Target.Kind == "" || m.JSONPatches[i].Target.Name == "" {
+				return errors.New("JSON patches must have a target kind and name when there is more than one manifest they can apply to")
+			}
+		}
+
 		// At this point, there is a reasonable chance that the patch is valid. Kustomize can handle
 		// further validation.
 		return nil
@@ -100,6 +123,9 @@ func (m *ManifestPatcher) Validate() error {
 			return err
 		}
 	}
+	for i := range m.JSONPatches {
+		setJSONPatchDefaults(apiVersion, kind, name, namespace, &m.JSONPatches[i])
+	}
 
 	return nil
 }
@@ -165,8 +191,104 @@ func setPatchDefaults(
 	return nil
 }
 
+// Returns the ID and content of the schema used to apply the patches, empty for the built-in schema
+func (m *ManifestPatcher) selectSchema(schema string) (schemaID string, schemaJSON []byte, err error) {
+	if m.BuiltInSchemaOnly {
+		return "", nil, nil
+	}
+	schemaID, schemaJSON, err = schemas.Schema(m.Manifests, schema)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to select the schema, err: %s", err)
+	}
+	return schemaID, schemaJSON, nil
+}
+
+// setPatchIdentity is a helper function for Validate that sets the missing apiVersion, kind and
+// namespace of a patch from the only manifest with the name of the patch, as PGT selects the
+// document of a multi-document source CR by name
+func setPatchIdentity(manifests []map[string]interface{}, patch map[string]interface{}) {
+	name, _, _ := unstructured.NestedString(patch, "metadata", "name")
+	if name == "" {
+		return
+	}
+	var matched map[string]interface{}
+	for _, manifest := range manifests {
+		manifestName, _, _ := unstructured.NestedString(manifest, "metadata", "name")
+		if manifestName != name {
+			continue
+		}
+		if matched != nil {
+			// Ambiguous, the patch must set its identity
+			return
+		}
+		matched = manifest
+	}
+	if matched == nil {
+		return
+	}
+	for _, field := range [][]string{{"apiVersion"}, {"kind"}, {"metadata", "namespace"}} {
+		value, _, _ := unstructured.NestedString(matched, field...)
+		if current, _, _ := unstructured.NestedString(patch, field...); current == "" && value != "" {
+			_ = unstructured.SetNestedField(patch, value, field...)
+		}
+	}
+}
+
+// PatchedTargets returns the patched manifests targeted by the patches, in the order of the patches,
+// each manifest once. The patches must have been validated. An error is returned if a targeted manifest
+// is not in the patched manifests
+func PatchedTargets(manifestPatches, patchedManifests []map[string]interface{}) (targets []map[string]interface{}, err error) {
+	matched := map[int]bool{}
+	for _, patch := range manifestPatches {
+		found := false
+		for index, manifest := range patchedManifests {
+			if identity(patch) != identity(manifest) {
+				continue
+			}
+			found = true
+			if !matched[index] {
+				matched[index] = true
+				targets = append(targets, manifest)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no patched manifest matches the patch target %s", identity(patch))
+		}
+	}
+	return targets, nil
+}
+
+// Returns the apiVersion, kind, namespace and name of a manifest
+func identity(manifest map[string]interface{}) string {
+	apiVersion, _, _ := unstructured.NestedString(manifest, "apiVersion")
+	kind, _, _ := unstructured.NestedString(manifest, "kind")
+	name, _, _ := unstructured.NestedString(manifest, "metadata", "name")
+	namespace, _, _ := unstructured.NestedString(manifest, "metadata", "namespace")
+	return fmt.Sprintf("%s/%s %s/%s", apiVersion, kind, namespace, name)
+}
+
+// setJSONPatchDefaults is a helper function for Validate that sets the missing target fields of a JSON
+// patch from the manifest
+func setJSONPatchDefaults(apiVersion, kind, name, namespace string, jsonPatch *JSONPatch) {
+	if jsonPatch.Target.Version == "" {
+		jsonPatch.Target.Version = apiVersion
+		if group, version, found := strings.Cut(apiVersion, "/"); found {
+			jsonPatch.Target.Group, jsonPatch.Target.Version = group, version
+		}
+	}
+	if jsonPatch.Target.Kind == "" {
+		jsonPatch.Target.Kind = kind
+	}
+	if jsonPatch.Target.Name == "" {
+		jsonPatch.Target.Name = name
+	}
+	if jsonPatch.Target.Namespace == "" {
+		jsonPatch.Target.Namespace = namespace
+	}
+}
+
 type KustomizeJSON struct {
-	Openapi   `yaml:"openapi"`
+	Openapi   `yaml:"openapi,omitempty"`
 	Patches   []Patch  `yaml:"patches"`
 	Resources []string `yaml:"resources"`
 }
@@ -175,24 +297,44 @@ type Openapi struct {
 }
 
 type Patch struct {
-	Path string `yaml:"path"`
+	Path   string       `yaml:"path"`
+	Target *PatchTarget `yaml:"target,omitempty"`
+}
+
+// PatchTarget selects the resource a Kustomize patch applies to
+type PatchTarget struct {
+	Group     string `yaml:"group,omitempty"`
+	Version   string `yaml:"version,omitempty"`
+	Kind      string `yaml:"kind,omitempty"`
+	Name      string `yaml:"name,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
 }
 
 type Resources []string
 
 // ApplyPatches applies the Kustomize patches on the input manifests using Kustomize and returns
-// the patched manifests. An error is returned if the patches can't be applied. This should be
-// run after the Validate method.
+// the patched manifests. The schema library definitions of the manifest kinds are used by default,
+// the optional schema overrides or extends them. Without any definition, Kustomize uses its built-in
+// schema which covers the Kubernetes kinds. An error is returned if the patches can't be applied.
+// This should be run after the Validate method.
 func (m *ManifestPatcher) ApplyPatches(schema string) ([]map[string]interface{}, error) {
-	// Create the file system in memory with the Kustomize YAML files
+	schemaID, schemaJSON, err := m.selectSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	err = loadSchema(schemaID, schemaJSON)
+	if err != nil {
+		return nil, err
+	}
 	fSys := filesys.MakeFsInMemory()
-
-	err := InitializeInMemoryKustomizeDir(fSys, schema)
+	err = InitializeInMemoryKustomizeDir(fSys)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize Kustomize dir, err: %s", err)
 	}
+
+	// The kustomization does not refer to the schema: Kustomize would parse it again on every run,
+	// while the schema loaded in the OpenAPI global state is kept and only parsed on first use
 	kustomizationYAMLFile := KustomizeJSON{}
-	kustomizationYAMLFile.Openapi.Path = localSchemaFileName
 
 	options := []struct {
 		optionType   string
@@ -230,29 +372,63 @@ func (m *ManifestPatcher) ApplyPatches(schema string) ([]map[string]interface{},
 			}
 		}
 	}
+	err = m.addJSONPatches(fSys, &kustomizationYAMLFile)
+	if err != nil {
+		return nil, err
+	}
 
 	return KustomizeManifest(fSys, &kustomizationYAMLFile)
 }
 
-const (
-	localSchemaFileName = "schema.json"
-	kustomizeDir        = "kustomize"
-)
+// Writes the JSON patches to the Kustomize directory and adds them with their target to the kustomization
+func (m *ManifestPatcher) addJSONPatches(fSys filesys.FileSystem, kustomizationYAMLFile *KustomizeJSON) error {
+	for i := range m.JSONPatches {
+		operationsYAML, err := yaml.Marshal(m.JSONPatches[i].Operations)
+		if err != nil {
+			return fmt.Errorf("an unexpected error occurred when converting the JSON patch back to YAML: %w", err)
+		}
+		patchFileName := fmt.Sprintf("jsonpatch%d.yaml", i)
+		err = fSys.WriteFile(path.Join(kustomizeDir, patchFileName), operationsYAML)
+		if err != nil {
+			return fmt.Errorf("an unexpected error occurred when writing the JSON patch: %w", err)
+		}
+		target := m.JSONPatches[i].Target
+		kustomizationYAMLFile.Patches = append(kustomizationYAMLFile.Patches, Patch{Path: patchFileName, Target: &target})
+	}
+	return nil
+}
 
-// Initializes the in-memory file system with base directory and open API schema
-func InitializeInMemoryKustomizeDir(fSys filesys.FileSystem, schema string) (err error) {
-	err = fSys.Mkdir(kustomizeDir)
-	if err != nil {
-		return fmt.Errorf("an unexpected error occurred when configuring Kustomize: %w", err)
+const kustomizeDir = "kustomize"
+
+// The ID of the schema loaded in the Kustomize OpenAPI global state, empty for the built-in schema. As
+// the OpenAPI schema is a global state of Kustomize, the patches must not be applied concurrently
+var loadedSchemaID string
+
+// Loads a schema in the Kustomize OpenAPI global state when switching to another schema. The state is
+// reset first since Kustomize adds the definitions of each schema it loads to the previous ones, so
+// without a reset the result of applying patches would depend on the schemas used before. Kustomize
+// parses the schema on first use, once per switch
+func loadSchema(schemaID string, schemaJSON []byte) error {
+	if schemaID == loadedSchemaID {
+		return nil
+	}
+	openapi.ResetOpenAPI()
+	loadedSchemaID = schemaID
+	if schemaJSON == nil {
+		return nil
 	}
-	schemaJSON, err := os.ReadFile(schema)
+	err := openapi.SetSchema(nil, schemaJSON, true)
 	if err != nil {
-		return fmt.Errorf("unable to open file: %s, err: %s ", schema, err)
+		return fmt.Errorf("failed to load the schema, err: %s", err)
 	}
+	return nil
+}
 
-	err = fSys.WriteFile(path.Join(kustomizeDir, localSchemaFileName), schemaJSON)
+// Initializes the in-memory file system with base directory
+func InitializeInMemoryKustomizeDir(fSys filesys.FileSystem) (err error) {
+	err = fSys.Mkdir(kustomizeDir)
 	if err != nil {
-		return fmt.Errorf("error writing schema, err:%s", err)
+		return fmt.Errorf("an unexpected error occurred when configuring Kustomize: %w", err)
 	}
 	return nil
 }
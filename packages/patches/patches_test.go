@@ -0,0 +1,139 @@
+package patches
+
+import (
+	"reflect"
+	"testing"
+)
+
+func ptpConfigManifest() map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "ptp.openshift.io/v1",
+		"kind":       "PtpConfig",
+		"metadata":   map[string]interface{}{"name": "du-ptp-slave", "namespace": "openshift-ptp"},
+		"spec": map[string]interface{}{
+			"profile": []interface{}{
+				map[string]interface{}{"name": "slave", "interface": "ens1f0", "ptp4lOpts": "-2 -s"},
+			},
+		},
+	}
+}
+
+func ptpConfigPatch() map[string]interface{} {
+	return map[string]interface{}{
+		"spec": map[string]interface{}{
+			"profile": []interface{}{
+				map[string]interface{}{"name": "slave", "interface": "ens5f0"},
+			},
+		},
+	}
+}
+
+// Applies the PtpConfig patch with the schema library, or with the Kustomize built-in schema only
+func applyPtpConfigPatch(t *testing.T, builtInSchemaOnly bool) []map[string]interface{} {
+	t.Helper()
+	patcher := ManifestPatcher{
+		Manifests:         []map[string]interface{}{ptpConfigManifest()},
+		Patches:           []map[string]interface{}{ptpConfigPatch()},
+		BuiltInSchemaOnly: builtInSchemaOnly,
+	}
+	err := patcher.Validate()
+	if err != nil {
+		t.Fatalf("Validate() error: %s", err)
+	}
+	patched, err := patcher.ApplyPatches("")
+	if err != nil {
+		t.Fatalf("ApplyPatches() error: %s", err)
+	}
+	return patched
+}
+
+func TestApplyPatchesDoesNotDependOnPreviousSchemas(t *testing.T) {
+	// The schema library merges the profiles by name, keeping ptp4lOpts, the built-in schema replaces them
+	libraryPatched := applyPtpConfigPatch(t, false)
+	builtInPatched := applyPtpConfigPatch(t, true)
+	libraryPatchedAgain := applyPtpConfigPatch(t, false)
+
+	profile := libraryPatched[0]["spec"].(map[string]interface{})["profile"].([]interface{})[0].(map[string]interface{})
+	if profile["interface"] != "ens5f0" || profile["ptp4lOpts"] != "-2 -s" {
+		t.Errorf("profile patched with the schema library = %v, want the merged profile", profile)
+	}
+	if reflect.DeepEqual(libraryPatched, builtInPatched) {
+		t.Errorf("patched with the built-in schema = %v, want the profile replaced", builtInPatched)
+	}
+	if !reflect.DeepEqual(libraryPatched, libraryPatchedAgain) {
+		t.Errorf("patched with the schema library after the built-in schema = %v, want %v", libraryPatchedAgain, libraryPatched)
+	}
+}
+
+// Returns the e810 plugin of the first PtpConfig profile
+func e810Plugin(manifest map[string]interface{}) map[string]interface{} {
+	profile := manifest["spec"].(map[string]interface{})["profile"].([]interface{})[0].(map[string]interface{})
+	return profile["plugins"].(map[string]interface{})["e810"].(map[string]interface{})
+}
+
+func TestInsertReplaceDirectivesReplacesSchemalessMaps(t *testing.T) {
+	for _, insertDirectives := range []bool{false, true} {
+		manifest := ptpConfigManifest()
+		profile := manifest["spec"].(map[string]interface{})["profile"].([]interface{})[0].(map[string]interface{})
+		profile["plugins"] = map[string]interface{}{"e810": map[string]interface{}{
+			"enableDefaultConfig": true,
+			"settings":            map[string]interface{}{"LocalMaxHoldoverOffSet": 1500},
+		}}
+		patch := ptpConfigPatch()
+		patchProfile := patch["spec"].(map[string]interface{})["profile"].([]interface{})[0].(map[string]interface{})
+		patchProfile["plugins"] = map[string]interface{}{"e810": map[string]interface{}{"enableDefaultConfig": false}}
+		patcher := ManifestPatcher{Manifests: []map[string]interface{}{manifest}, Patches: []map[string]interface{}{patch}}
+		if insertDirectives {
+			fieldPaths, err := patcher.InsertReplaceDirectives("")
+			if err != nil {
+				t.Fatalf("InsertReplaceDirectives() error: %s", err)
+			}
+			if !reflect.DeepEqual(fieldPaths, []string{"spec.profile[0].plugins"}) {
+				t.Errorf("InsertReplaceDirectives() = %v, want [spec.profile[0].plugins]", fieldPaths)
+			}
+		}
+		err := patcher.Validate()
+		if err != nil {
+			t.Fatalf("Validate() error: %s", err)
+		}
+		patched, err := patcher.ApplyPatches("")
+		if err != nil {
+			t.Fatalf("ApplyPatches() error: %s", err)
+		}
+
+		// The settings omitted by the patch are only kept when the plugins are merged
+		plugin := e810Plugin(patched[0])
+		_, hasSettings := plugin["settings"]
+		if plugin["enableDefaultConfig"] != false || hasSettings == insertDirectives {
+			t.Errorf("e810 plugin patched with directives %t = %v", insertDirectives, plugin)
+		}
+	}
+}
+
+func TestApplyPatchesReplacesPtpConfigMatch(t *testing.T) {
+	manifest := ptpConfigManifest()
+	manifest["spec"].(map[string]interface{})["recommend"] = []interface{}{map[string]interface{}{
+		"profile": "slave", "priority": 4,
+		"match": []interface{}{map[string]interface{}{"nodeLabel": "node-role.kubernetes.io/master"}},
+	}}
+	patch := map[string]interface{}{"spec": map[string]interface{}{"recommend": []interface{}{map[string]interface{}{
+		"profile": "slave",
+		"match":   []interface{}{map[string]interface{}{"nodeLabel": "node-role.kubernetes.io/worker"}},
+	}}}}
+	patcher := ManifestPatcher{Manifests: []map[string]interface{}{manifest}, Patches: []map[string]interface{}{patch}}
+	err := patcher.Validate()
+	if err != nil {
+		t.Fatalf("Validate() error: %s", err)
+	}
+	patched, err := patcher.ApplyPatches("")
+	if err != nil {
+		t.Fatalf("ApplyPatches() error: %s", err)
+	}
+
+	// The match entries only set nodeLabel and have no merge key, the patch replaces them
+	recommend := patched[0]["spec"].(map[string]interface{})["recommend"].([]interface{})[0].(map[string]interface{})
+	want := []interface{}{map[string]interface{}{"nodeLabel": "node-role.kubernetes.io/worker"}}
+	if !reflect.DeepEqual(recommend["match"], want) || recommend["priority"] != 4 {
+		t.Errorf("patched recommend = %v, want the match list %v and the priority kept", recommend, want)
+	}
+}
@@ -0,0 +1,82 @@
+package patches
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// NeedsPreRendering returns whether the patches of a manifest should be pre-rendered and why. The ACM
+// policy generator applies the patches with the Kustomize built-in schema only: the patches of custom
+// resources and the lists of objects without merge key in the built-in schema are not merged as
+// intended, so they must be pre-rendered. The patches of the built-in kinds are merged correctly
+func NeedsPreRendering(manifests, manifestPatches []map[string]interface{}) (needed bool, reason string) {
+	_ = loadSchema("", nil)
+
+	for _, manifest := range manifests {
+		apiVersion, _ := manifest["apiVersion"].(string)
+		kind, _ := manifest["kind"].(string)
+		schema := openapi.SchemaForResourceType(kyaml.TypeMeta{APIVersion: apiVersion, Kind: kind})
+		if schema == nil {
+			return true, fmt.Sprintf("%s %s is a custom resource without built-in strategic merge metadata", apiVersion, kind)
+		}
+		for _, patch := range manifestPatches {
+			if lists := unmergedObjectLists(schema, patch, ""); len(lists) != 0 {
+				return true, fmt.Sprintf("the patch contains lists of objects without merge key in the %s %s built-in schema: %s",
+					apiVersion, kind, strings.Join(lists, ", "))
+			}
+		}
+	}
+	return false, "built-in kind, Kustomize merges the patches with its built-in schema"
+}
+
+// Returns the paths of the lists of objects of the patch content that have no merge key in the schema
+func unmergedObjectLists(schema *openapi.ResourceSchema, content interface{}, fieldPath string) (paths []string) {
+	switch typedContent := content.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(typedContent))
+		for key := range typedContent {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			var fieldSchema *openapi.ResourceSchema
+			if schema != nil {
+				fieldSchema = schema.Field(key)
+			}
+			paths = append(paths, unmergedObjectLists(fieldSchema, typedContent[key], joinFieldPath(fieldPath, key))...)
+		}
+	case []interface{}:
+		var elementsSchema *openapi.ResourceSchema
+		if schema != nil {
+			elementsSchema = schema.Elements()
+		}
+		hasObjects := false
+		for _, item := range typedContent {
+			if _, ok := item.(map[string]interface{}); ok {
+				hasObjects = true
+			}
+			paths = append(paths, unmergedObjectLists(elementsSchema, item, fieldPath+"[]")...)
+		}
+		if !hasObjects {
+			return paths
+		}
+		if schema == nil {
+			return append([]string{fieldPath}, paths...)
+		}
+		if _, mergeKey := schema.PatchStrategyAndKey(); mergeKey == "" {
+			return append([]string{fieldPath}, paths...)
+		}
+	}
+	return paths
+}
+
+func joinFieldPath(fieldPath, name string) string {
+	if fieldPath == "" {
+		return name
+	}
+	return fieldPath + "." + name
+}
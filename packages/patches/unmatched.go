@@ -0,0 +1,72 @@
+package patches
+
+import (
+	"github.com/test-network-function/pgt2acm/packages/stringhelper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// The namespace Kustomize gives to the resources without namespace when matching the patches
+const defaultNamespace = "default"
+
+// UnmatchedPatch is a patch matching no manifest, as reported by Kustomize with "no matches for Id"
+type UnmatchedPatch struct {
+	// The index of the patch in the patches
+	PatchIndex int
+	// The index of the manifest of the patch kind with the closest name and namespace, -1 if no
+	// manifest has the patch kind
+	ClosestIndex int
+}
+
+// UnmatchedPatches returns the patches whose apiVersion, kind, name and namespace match no manifest,
+// together with the closest manifest of the same kind: the one with the smallest edit distance between
+// the names and namespaces. A patch without kind is compared to all the manifests. This should be run
+// after the Validate method.
+func (m *ManifestPatcher) UnmatchedPatches() (unmatched []UnmatchedPatch) {
+	for patchIndex, patch := range m.Patches {
+		if m.matchesManifest(patch) {
+			continue
+		}
+		unmatchedPatch := UnmatchedPatch{PatchIndex: patchIndex, ClosestIndex: -1}
+		closestDistance := 0
+		patchKind := StringField(patch, "kind")
+		for manifestIndex, manifest := range m.Manifests {
+			if patchKind != "" && patchKind != StringField(manifest, "kind") {
+				continue
+			}
+			distance := stringhelper.EditDistance(StringField(patch, "metadata", "name"), StringField(manifest, "metadata", "name")) +
+				stringhelper.EditDistance(namespace(patch), namespace(manifest))
+			if unmatchedPatch.ClosestIndex == -1 || distance < closestDistance {
+				unmatchedPatch.ClosestIndex, closestDistance = manifestIndex, distance
+			}
+		}
+		unmatched = append(unmatched, unmatchedPatch)
+	}
+	return unmatched
+}
+
+// Returns true if a manifest has the apiVersion, kind, name and namespace of the patch
+func (m *ManifestPatcher) matchesManifest(patch map[string]interface{}) bool {
+	for _, manifest := range m.Manifests {
+		if StringField(patch, "apiVersion") == StringField(manifest, "apiVersion") &&
+			StringField(patch, "kind") == StringField(manifest, "kind") &&
+			StringField(patch, "metadata", "name") == StringField(manifest, "metadata", "name") &&
+			namespace(patch) == namespace(manifest) {
+			return true
+		}
+	}
+	return false
+}
+
+// StringField returns the string value of a field of a manifest or patch, empty if not set
+func StringField(object map[string]interface{}, fields ...string) string {
+	value, _, _ := unstructured.NestedString(object, fields...)
+	return value
+}
+
+// Returns the namespace of a manifest or patch as Kustomize matches it, the default namespace if not set
+func namespace(object map[string]interface{}) string {
+	if value := StringField(object, "metadata", "namespace"); value != "" {
+		return value
+	}
+	return defaultNamespace
+}
@@ -0,0 +1,42 @@
+package patches
+
+import (
+	"reflect"
+	"testing"
+)
+
+func configMap(name, namespace string) map[string]interface{} {
+	metadata := map[string]interface{}{"name": name}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	return map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "metadata": metadata}
+}
+
+func TestUnmatchedPatches(t *testing.T) {
+	ptpConfig := ptpConfigManifest()
+	patcher := ManifestPatcher{
+		Manifests: []map[string]interface{}{configMap("first-config", "default"), configMap("second-config", ""), ptpConfig},
+		Patches: []map[string]interface{}{
+			// Matches the second ConfigMap, whose namespace defaults to default
+			configMap("second-config", "default"),
+			// Closest to the second ConfigMap by name
+			configMap("secnd-config", "default"),
+			// Closest to the first ConfigMap by namespace
+			configMap("first-config", "defualt"),
+			// No manifest of the kind
+			{"apiVersion": "v1", "kind": "Secret", "metadata": map[string]interface{}{"name": "first-config"}},
+			// Without kind, compared to all the manifests
+			{"metadata": map[string]interface{}{"name": "du-ptp-slav", "namespace": "openshift-ptp"}},
+		},
+	}
+	want := []UnmatchedPatch{
+		{PatchIndex: 1, ClosestIndex: 1},
+		{PatchIndex: 2, ClosestIndex: 0},
+		{PatchIndex: 3, ClosestIndex: -1},
+		{PatchIndex: 4, ClosestIndex: 2},
+	}
+	if got := patcher.UnmatchedPatches(); !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmatchedPatches() = %v, want %v", got, want)
+	}
+}
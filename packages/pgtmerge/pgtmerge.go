@@ -0,0 +1,178 @@
+// The merge logic in this file reproduces how the PolicyGenTemplate plugin applies the overrides
+// of a source file on a source CR, see
+// https://github.com/openshift-kni/cnf-features-deploy/tree/master/ztp/policygenerator
+package pgtmerge
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Merge returns a copy of the source CR content with the PGT overrides merged in. Maps are merged
+// recursively, lists are merged item by item at the same index and any other value is replaced by
+// the override. As with PGT, the fields still holding a $ placeholder such as "$node" once merged are
+// unset and removed, except the $mcp keyword that PGT renders before merging. The inputs are not modified.
+func Merge(source, override map[string]interface{}) map[string]interface{} {
+	merged := mergeMaps(source, override)
+	removeUnsetFields(merged)
+	return merged
+}
+
+func mergeMaps(source, override map[string]interface{}) map[string]interface{} {
+	merged := DeepCopy(source)
+	if merged == nil {
+		merged = map[string]interface{}{}
+	}
+	for key, overrideValue := range override {
+		merged[key] = mergeValue(merged[key], overrideValue)
+	}
+	return merged
+}
+
+func mergeValue(source, override interface{}) interface{} {
+	switch overrideValue := override.(type) {
+	case map[string]interface{}:
+		sourceMap, ok := source.(map[string]interface{})
+		if !ok {
+			return deepCopyValue(overrideValue)
+		}
+		return mergeMaps(sourceMap, overrideValue)
+	case []interface{}:
+		sourceList, ok := source.([]interface{})
+		if !ok {
+			return deepCopyValue(overrideValue)
+		}
+		merged := make([]interface{}, len(overrideValue))
+		for index := range overrideValue {
+			if index < len(sourceList) {
+				merged[index] = mergeValue(sourceList[index], overrideValue[index])
+				continue
+			}
+			merged[index] = deepCopyValue(overrideValue[index])
+		}
+		return merged
+	default:
+		return override
+	}
+}
+
+// The keyword replaced by the MachineConfigPool name in the source CRs
+const mcpKeyword = "$mcp"
+
+// Removes the map fields whose value is a string starting with $, in the maps nested in maps and lists
+func removeUnsetFields(value interface{}) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, fieldValue := range typedValue {
+			if stringValue, ok := fieldValue.(string); ok && strings.HasPrefix(stringValue, "$") && stringValue != mcpKeyword {
+				delete(typedValue, key)
+				continue
+			}
+			removeUnsetFields(fieldValue)
+		}
+	case []interface{}:
+		for _, item := range typedValue {
+			removeUnsetFields(item)
+		}
+	}
+}
+
+// DeepCopy returns a deep copy of a generic YAML map
+func DeepCopy(source map[string]interface{}) map[string]interface{} {
+	if source == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(source))
+	for key, value := range source {
+		copied[key] = deepCopyValue(value)
+	}
+	return copied
+}
+
+func deepCopyValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		return DeepCopy(typedValue)
+	case []interface{}:
+		copied := make([]interface{}, len(typedValue))
+		for index := range typedValue {
+			copied[index] = deepCopyValue(typedValue[index])
+		}
+		return copied
+	default:
+		return value
+	}
+}
+
+// Difference is a field whose value differs between two merged resources
+type Difference struct {
+	// The JSON path of the field, for instance $.spec.profile[0].interface
+	Path string
+	// The values of the field in each resource, nil if the field is absent
+	Left, Right interface{}
+	// Whether the field is present in each resource
+	InLeft, InRight bool
+}
+
+// Diff returns the differences between two generic YAML values, in a stable order. Maps are compared
+// key by key and lists item by item
+func Diff(left, right interface{}) []Difference {
+	return diffValues(left, right, "$")
+}
+
+func diffValues(left, right interface{}, path string) (differences []Difference) {
+	leftMap, leftIsMap := left.(map[string]interface{})
+	rightMap, rightIsMap := right.(map[string]interface{})
+	if leftIsMap && rightIsMap {
+		keys := map[string]bool{}
+		for key := range leftMap {
+			keys[key] = true
+		}
+		for key := range rightMap {
+			keys[key] = true
+		}
+		for _, key := range sortedKeys(keys) {
+			leftValue, inLeft := leftMap[key]
+			rightValue, inRight := rightMap[key]
+			fieldPath := path + "." + key
+			if !inLeft || !inRight {
+				differences = append(differences, Difference{Path: fieldPath, Left: leftValue, Right: rightValue, InLeft: inLeft, InRight: inRight})
+				continue
+			}
+			differences = append(differences, diffValues(leftValue, rightValue, fieldPath)...)
+		}
+		return differences
+	}
+
+	leftList, leftIsList := left.([]interface{})
+	rightList, rightIsList := right.([]interface{})
+	if leftIsList && rightIsList {
+		for index := 0; index < len(leftList) || index < len(rightList); index++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, index)
+			switch {
+			case index >= len(rightList):
+				differences = append(differences, Difference{Path: itemPath, Left: leftList[index], InLeft: true})
+			case index >= len(leftList):
+				differences = append(differences, Difference{Path: itemPath, Right: rightList[index], InRight: true})
+			default:
+				differences = append(differences, diffValues(leftList[index], rightList[index], itemPath)...)
+			}
+		}
+		return differences
+	}
+
+	if !reflect.DeepEqual(left, right) {
+		differences = append(differences, Difference{Path: path, Left: left, Right: right, InLeft: true, InRight: true})
+	}
+	return differences
+}
+
+func sortedKeys(keys map[string]bool) (sorted []string) {
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,103 @@
+package placement
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	apiGroup = "cluster.open-cluster-management.io"
+	// The Placement API version used when the configuration does not set one
+	DefaultAPIVersion = apiGroup + "/v1beta1"
+	// The taint of the managed clusters that are not yet available
+	unreachableTaintKey = apiGroup + "/unreachable"
+)
+
+// Config holds the options of the generated placements, read from the placement configuration file
+type Config struct {
+	// The Placement API version, cluster.open-cluster-management.io/v1beta1 by default
+	APIVersion string `yaml:"apiVersion"`
+	// The tolerations of the placements. The unreachable taint is tolerated by default, so that the
+	// child policies are created as soon as the managed cluster starts installing
+	Tolerations []Toleration `yaml:"tolerations"`
+	// The ManagedClusterSets the clusters are selected from, all the bound sets if empty
+	ClusterSets []string `yaml:"clusterSets"`
+	// The number of clusters selected, for instance for a staged rollout, all the matching clusters if unset
+	NumberOfClusters *int32 `yaml:"numberOfClusters"`
+	// The prioritizers ordering the clusters when NumberOfClusters is set
+	PrioritizerPolicy *PrioritizerPolicy `yaml:"prioritizerPolicy"`
+	// The cluster claims selector added to the binding rules label selector
+	ClaimSelector map[string]interface{} `yaml:"claimSelector"`
+}
+
+// DefaultConfig returns the configuration of the placements generated without configuration file
+func DefaultConfig() *Config {
+	return &Config{
+		APIVersion:  DefaultAPIVersion,
+		Tolerations: []Toleration{{Key: unreachableTaintKey, Operator: "Exists"}},
+	}
+}
+
+// LoadConfig reads a placement configuration file. The missing API version and tolerations are set to
+// the default ones, an empty list of tolerations disables them. An error is returned if the file has
+// unknown fields or invalid values
+func LoadConfig(configFile string) (config *Config, err error) {
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %s, err: %s ", configFile, err)
+	}
+	config = &Config{}
+	err = yaml.UnmarshalStrict(content, config)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse placement configuration: %s, err: %s", configFile, err)
+	}
+	defaults := DefaultConfig()
+	if config.APIVersion == "" {
+		config.APIVersion = defaults.APIVersion
+	}
+	if config.Tolerations == nil {
+		config.Tolerations = defaults.Tolerations
+	}
+	err = config.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid placement configuration: %s, err: %s", configFile, err)
+	}
+	return config, nil
+}
+
+// Checks the values the Placement API would reject
+func (c *Config) validate() error {
+	if !strings.HasPrefix(c.APIVersion, apiGroup+"/") {
+		return fmt.Errorf("apiVersion %s is not in the %s group", c.APIVersion, apiGroup)
+	}
+	for _, toleration := range c.Tolerations {
+		if toleration.Operator != "" && toleration.Operator != "Exists" && toleration.Operator != "Equal" {
+			return fmt.Errorf("toleration %s: operator must be Exists or Equal, got %s", toleration.Key, toleration.Operator)
+		}
+		if toleration.Effect != "" && toleration.Effect != "NoSelect" && toleration.Effect != "PreferNoSelect" && toleration.Effect != "NoSelectIfNew" {
+			return fmt.Errorf("toleration %s: effect must be NoSelect, PreferNoSelect or NoSelectIfNew, got %s", toleration.Key, toleration.Effect)
+		}
+		// The Placement API ignores the toleration seconds of the other effects
+		if toleration.TolerationSeconds != nil && toleration.Effect != "NoSelect" && toleration.Effect != "PreferNoSelect" {
+			return fmt.Errorf("toleration %s: tolerationSeconds requires the NoSelect or PreferNoSelect effect, got %q", toleration.Key, toleration.Effect)
+		}
+	}
+	if c.NumberOfClusters != nil && *c.NumberOfClusters < 1 {
+		return fmt.Errorf("numberOfClusters must be at least 1, got %d", *c.NumberOfClusters)
+	}
+	if c.PrioritizerPolicy == nil {
+		return nil
+	}
+	if mode := c.PrioritizerPolicy.Mode; mode != "" && mode != "Additive" && mode != "Exact" {
+		return fmt.Errorf("prioritizerPolicy mode must be Additive or Exact, got %s", mode)
+	}
+	for _, configuration := range c.PrioritizerPolicy.Configurations {
+		if coordinateType := configuration.ScoreCoordinate.Type; coordinateType != "BuiltIn" && coordinateType != "AddOn" {
+			return fmt.Errorf("prioritizerPolicy scoreCoordinate type must be BuiltIn or AddOn, got %s", coordinateType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package placement
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Writes a placement configuration file and loads it
+func loadConfigContent(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	configFile := filepath.Join(t.TempDir(), "placement-config.yaml")
+	err := os.WriteFile(configFile, []byte(content), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return LoadConfig(configFile)
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	config, err := loadConfigContent(t, "clusterSets:\n  - canary\n")
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %s", err)
+	}
+	want := DefaultConfig()
+	want.ClusterSets = []string{"canary"}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("LoadConfig() = %+v, want %+v", config, want)
+	}
+
+	config, err = loadConfigContent(t, "tolerations: []\n")
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %s", err)
+	}
+	if len(config.Tolerations) != 0 {
+		t.Errorf("LoadConfig() tolerations = %+v, want none", config.Tolerations)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown field", "numberOfCluster: 2\n", "field numberOfCluster not found"},
+		{"wrong type", "numberOfClusters: two\n", "cannot unmarshal"},
+		{"API group", "apiVersion: cluster.x-k8s.io/v1beta1\n", "apiVersion cluster.x-k8s.io/v1beta1 is not in the cluster.open-cluster-management.io group"},
+		{"operator", "tolerations:\n  - key: gpu\n    operator: In\n", "toleration gpu: operator must be Exists or Equal, got In"},
+		{"effect", "tolerations:\n  - key: gpu\n    effect: NoSchedule\n", "toleration gpu: effect must be NoSelect, PreferNoSelect or NoSelectIfNew, got NoSchedule"},
+		{"toleration seconds without effect", "tolerations:\n  - key: gpu\n    tolerationSeconds: 300\n",
+			`toleration gpu: tolerationSeconds requires the NoSelect or PreferNoSelect effect, got ""`},
+		{"toleration seconds of NoSelectIfNew", "tolerations:\n  - key: gpu\n    effect: NoSelectIfNew\n    tolerationSeconds: 300\n",
+			`toleration gpu: tolerationSeconds requires the NoSelect or PreferNoSelect effect, got "NoSelectIfNew"`},
+		{"number of clusters", "numberOfClusters: 0\n", "numberOfClusters must be at least 1, got 0"},
+		{"prioritizer mode", "prioritizerPolicy:\n  mode: Sum\n", "prioritizerPolicy mode must be Additive or Exact, got Sum"},
+		{"score coordinate", "prioritizerPolicy:\n  configurations:\n    - scoreCoordinate:\n        type: Custom\n",
+			"prioritizerPolicy scoreCoordinate type must be BuiltIn or AddOn, got Custom"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := loadConfigContent(t, test.content)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("LoadConfig() error = %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
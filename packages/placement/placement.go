@@ -17,22 +17,47 @@ type Placement struct {
 		Namespace string `yaml:"namespace"`
 	} `yaml:"metadata"`
 	Spec struct {
-		Predicates  []Predicate  `yaml:"predicates"`
-		Tolerations []Toleration `yaml:"tolerations"`
+		ClusterSets       []string           `yaml:"clusterSets,omitempty"`
+		NumberOfClusters  *int32             `yaml:"numberOfClusters,omitempty"`
+		Predicates        []Predicate        `yaml:"predicates"`
+		PrioritizerPolicy *PrioritizerPolicy `yaml:"prioritizerPolicy,omitempty"`
+		Tolerations       []Toleration       `yaml:"tolerations,omitempty"`
 	} `yaml:"spec"`
 }
 type Predicate struct {
 	RequiredClusterSelector struct {
 		LabelSelector map[string]interface{} `yaml:"labelSelector"`
+		ClaimSelector map[string]interface{} `yaml:"claimSelector,omitempty"`
 	} `yaml:"requiredClusterSelector"`
 }
 type Toleration struct {
-	Key      string `yaml:"key"`
-	Operator string `yaml:"operator"`
+	Key               string `yaml:"key,omitempty"`
+	Operator          string `yaml:"operator,omitempty"`
+	Value             string `yaml:"value,omitempty"`
+	Effect            string `yaml:"effect,omitempty"`
+	TolerationSeconds *int64 `yaml:"tolerationSeconds,omitempty"`
+}
+type PrioritizerPolicy struct {
+	Mode           string                     `yaml:"mode,omitempty"`
+	Configurations []PrioritizerConfiguration `yaml:"configurations,omitempty"`
+}
+type PrioritizerConfiguration struct {
+	ScoreCoordinate struct {
+		Type    string `yaml:"type"`
+		BuiltIn string `yaml:"builtIn,omitempty"`
+		AddOn   *struct {
+			ResourceName string `yaml:"resourceName"`
+			ScoreName    string `yaml:"scoreName"`
+		} `yaml:"addOn,omitempty"`
+	} `yaml:"scoreCoordinate"`
+	Weight *int32 `yaml:"weight,omitempty"`
 }
 
-func GeneratePlacementFile(policyName, policyNamespace, outputDir string, labelSelector map[string]interface{}) (placementPathRelative string, err error) {
-	placement := Placement{APIVersion: "cluster.open-cluster-management.io/v1beta1",
+// Generates the placement of a policy selecting the clusters with the label selector, with the API
+// version, tolerations, cluster sets, number of clusters, prioritizers and claim selector of the
+// placement configuration
+func GeneratePlacementFile(policyName, policyNamespace, outputDir string, labelSelector map[string]interface{}, config *Config) (placementPathRelative string, err error) {
+	placement := Placement{APIVersion: config.APIVersion,
 		Kind: "Placement"}
 	placement.Metadata.Name = "placement-" + policyName
 	placement.Metadata.Namespace = policyNamespace
@@ -40,13 +65,14 @@ func GeneratePlacementFile(policyName, policyNamespace, outputDir string, labelS
 	// adding predicate
 	predicate := Predicate{}
 	predicate.RequiredClusterSelector.LabelSelector = labelSelector
+	predicate.RequiredClusterSelector.ClaimSelector = config.ClaimSelector
 	placement.Spec.Predicates = append(placement.Spec.Predicates, predicate)
 
-	// toleration to create child policies even if the managed cluster is not yet available
-	toleration := Toleration{}
-	toleration.Key = "cluster.open-cluster-management.io/unreachable"
-	toleration.Operator = "Exists"
-	placement.Spec.Tolerations = append(placement.Spec.Tolerations, toleration)
+	// by default, toleration to create child policies even if the managed cluster is not yet available
+	placement.Spec.Tolerations = config.Tolerations
+	placement.Spec.ClusterSets = config.ClusterSets
+	placement.Spec.NumberOfClusters = config.NumberOfClusters
+	placement.Spec.PrioritizerPolicy = config.PrioritizerPolicy
 
 	placementPathRelative = policyName + "-placement.yaml"
 	placementPath := filepath.Join(outputDir, placementPathRelative)
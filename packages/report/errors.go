@@ -0,0 +1,32 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error is an error located in a file. Its message starts with the position, as the report entries do,
+// so that editors and CI annotations can match it
+type Error struct {
+	Position Position
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Position, e.Message)
+}
+
+// Errorf returns an error located at a position
+func Errorf(position Position, format string, args ...interface{}) error {
+	return &Error{Position: position, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrapf adds context to an error. The position of an error already located is kept at the start of the
+// message, it is more precise than the position of the caller. Other errors are located at the given position
+func Wrapf(err error, position Position, format string, args ...interface{}) error {
+	var located *Error
+	if errors.As(err, &located) {
+		return &Error{Position: located.Position, Message: fmt.Sprintf(format, args...) + ": " + located.Message}
+	}
+	return &Error{Position: position, Message: fmt.Sprintf(format, args...) + ": " + err.Error()}
+}
@@ -0,0 +1,103 @@
+package report
+
+import (
+	"fmt"
+	"io"
+)
+
+type Level string
+
+const (
+	LevelInfo    Level = "info"
+	LevelWarning Level = "warning"
+)
+
+// Position locates a message in a file. Line and Column start at 1, a zero Line means the whole file
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String returns the position in the file:line:col format understood by editors and CI annotations
+func (p Position) String() string {
+	if p.Line == 0 {
+		return p.File
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Entry is a single message about a converted file
+type Entry struct {
+	Level Level
+	// The file the message relates to
+	File string
+	// The optional line and column the message relates to in the file
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e Entry) String() string {
+	return fmt.Sprintf("%s: %s: %s", Position{File: e.File, Line: e.Line, Column: e.Column}, e.Level, e.Message)
+}
+
+// Report collects the decisions and warnings produced during a conversion so that they can be
+// reviewed once all the files are converted
+type Report struct {
+	Entries []Entry
+}
+
+// Adds an informational message to the report
+func (r *Report) Infof(file, format string, args ...interface{}) {
+	r.add(Entry{Level: LevelInfo, File: file, Message: fmt.Sprintf(format, args...)})
+}
+
+// Adds a warning to the report
+func (r *Report) Warnf(file, format string, args ...interface{}) {
+	r.add(Entry{Level: LevelWarning, File: file, Message: fmt.Sprintf(format, args...)})
+}
+
+// Adds an informational message about a position in a file to the report
+func (r *Report) InfofAt(position Position, format string, args ...interface{}) {
+	r.add(Entry{Level: LevelInfo, File: position.File, Line: position.Line, Column: position.Column,
+		Message: fmt.Sprintf(format, args...)})
+}
+
+// Adds a warning about a position in a file to the report
+func (r *Report) WarnfAt(position Position, format string, args ...interface{}) {
+	r.add(Entry{Level: LevelWarning, File: position.File, Line: position.Line, Column: position.Column,
+		Message: fmt.Sprintf(format, args...)})
+}
+
+// Adds an entry unless it is already in the report, as when the same source file is patched by several
+// steps of the conversion
+func (r *Report) add(entry Entry) {
+	for _, reported := range r.Entries {
+		if reported == entry {
+			return
+		}
+	}
+	r.Entries = append(r.Entries, entry)
+}
+
+// Returns the number of warnings in the report
+func (r *Report) WarningCount() (count int) {
+	for _, entry := range r.Entries {
+		if entry.Level == LevelWarning {
+			count++
+		}
+	}
+	return count
+}
+
+// Prints all the entries of the report, one per line
+func (r *Report) Print(w io.Writer) {
+	if len(r.Entries) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Conversion report (%d entries, %d warnings):\n", len(r.Entries), r.WarningCount())
+	for _, entry := range r.Entries {
+		fmt.Fprintln(w, entry.String())
+	}
+}
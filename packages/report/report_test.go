@@ -0,0 +1,52 @@
+package report
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		position Position
+		want     string
+	}{
+		{Position{File: "pgt.yaml"}, "pgt.yaml"},
+		{Position{File: "pgt.yaml", Line: 11, Column: 7}, "pgt.yaml:11:7"},
+	}
+	for _, test := range tests {
+		if got := test.position.String(); got != test.want {
+			t.Errorf("%#v.String() = %q, want %q", test.position, got, test.want)
+		}
+	}
+}
+
+func TestWrapf(t *testing.T) {
+	pgtPosition := Position{File: "pgt.yaml", Line: 1, Column: 1}
+	located := Errorf(Position{File: "pgt.yaml", Line: 11, Column: 7}, "could not render")
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"located error", located, "pgt.yaml:11:7: could not convert PolicyGenTemplate pgt: could not render"},
+		{"error without position", errors.New("could not render"), "pgt.yaml:1:1: could not convert PolicyGenTemplate pgt: could not render"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Wrapf(test.err, pgtPosition, "could not convert PolicyGenTemplate %s", "pgt").Error(); got != test.want {
+				t.Errorf("Wrapf() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReportSkipsDuplicateEntries(t *testing.T) {
+	report := Report{}
+	position := Position{File: "pgt.yaml", Line: 16, Column: 9}
+	report.WarnfAt(position, "source file %s: the patch matches no resource", "PtpConfigSlave.yaml")
+	report.WarnfAt(position, "source file %s: the patch matches no resource", "PtpConfigSlave.yaml")
+	report.InfofAt(position, "source file %s: the patch matches no resource", "PtpConfigSlave.yaml")
+	if len(report.Entries) != 2 {
+		t.Errorf("report entries = %v, want the warning once and the info", report.Entries)
+	}
+}
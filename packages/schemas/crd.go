@@ -0,0 +1,198 @@
+package schemas
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/test-network-function/pgt2acm/packages/report"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	crdKind             = "CustomResourceDefinition"
+	mergeKeyExtension   = "x-kubernetes-patch-merge-key"
+	strategyExtension   = "x-kubernetes-patch-strategy"
+	listTypeExtension   = "x-kubernetes-list-type"
+	listMapKeyExtension = "x-kubernetes-list-map-keys"
+	// The list item field guessed as merge key when the CRD does not declare one
+	guessedMergeKey = "name"
+)
+
+// FromCRDFiles converts the openAPIV3Schema of every served version of the CustomResourceDefinitions
+// found in the files to the Kustomize OpenAPI schema format. The merge keys of the lists of objects
+// are taken from the list map keys declared by the CRD, or guessed from a name field in the list
+// items. The guesses and the lists left without merge key are reported for review
+func FromCRDFiles(files []string, conversionReport *report.Report) (schemaJSON []byte, err error) {
+	schema := schemaFile{Definitions: map[string]interface{}{}}
+	for _, file := range files {
+		var crds []map[string]interface{}
+		crds, err = readCRDs(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, crd := range crds {
+			err = convertCRD(crd, file, schema.Definitions, conversionReport)
+			if err != nil {
+				return nil, fmt.Errorf("could not convert CRD in file: %s, err: %s", file, err)
+			}
+		}
+	}
+	if len(schema.Definitions) == 0 {
+		return nil, errors.New("no CustomResourceDefinition with an openAPIV3Schema found")
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// Returns the CustomResourceDefinitions of a multi-document YAML file
+func readCRDs(file string) (crds []map[string]interface{}, err error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %s, err: %s ", file, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		document := map[string]interface{}{}
+		err = decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return crds, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not decode file: %s, err: %s", file, err)
+		}
+		if document["kind"] == crdKind {
+			crds = append(crds, document)
+		}
+	}
+}
+
+// Adds the definitions of the served versions of a CRD to the definitions
+func convertCRD(crd map[string]interface{}, file string, definitions map[string]interface{}, conversionReport *report.Report) error {
+	spec, _ := crd["spec"].(map[string]interface{})
+	group, _ := spec["group"].(string)
+	names, _ := spec["names"].(map[string]interface{})
+	kind, _ := names["kind"].(string)
+	if group == "" || kind == "" {
+		return errors.New("spec.group and spec.names.kind must be set")
+	}
+
+	versions, _ := spec["versions"].([]interface{})
+	for _, item := range versions {
+		version, _ := item.(map[string]interface{})
+		versionName, _ := version["name"].(string)
+		if served, ok := version["served"].(bool); ok && !served {
+			continue
+		}
+		validation, _ := version["schema"].(map[string]interface{})
+		if validation == nil {
+			// apiextensions.k8s.io/v1beta1 CRDs may define a single schema for all the versions
+			validation, _ = spec["validation"].(map[string]interface{})
+		}
+		openAPISchema, _ := validation["openAPIV3Schema"].(map[string]interface{})
+		if openAPISchema == nil {
+			conversionReport.Warnf(file, "%s/%s %s: no openAPIV3Schema, skipped", group, versionName, kind)
+			continue
+		}
+
+		definition := convertSchema(openAPISchema, file, kind, "", conversionReport).(map[string]interface{})
+		definition[gvkExtension] = []interface{}{map[string]interface{}{"group": group, "kind": kind, "version": versionName}}
+		definitions[definitionName(group, versionName, kind)] = definition
+	}
+	return nil
+}
+
+// Returns the definition name used by Kubernetes for a kind, for instance io.openshift.ptp.v1.PtpConfig
+// for the ptp.openshift.io/v1 PtpConfig kind
+func definitionName(group, version, kind string) string {
+	groupParts := strings.Split(group, ".")
+	for i, j := 0, len(groupParts)-1; i < j; i, j = i+1, j-1 {
+		groupParts[i], groupParts[j] = groupParts[j], groupParts[i]
+	}
+	return strings.Join(append(groupParts, version, kind), ".")
+}
+
+// Converts an openAPIV3Schema node: descriptions are dropped and the strategic merge extensions are
+// added to the lists of objects. fieldPath is the path of the node in the resource, used in the report
+func convertSchema(node interface{}, file, kind, fieldPath string, conversionReport *report.Report) interface{} {
+	nodeMap, ok := node.(map[string]interface{})
+	if !ok {
+		return node
+	}
+	converted := map[string]interface{}{}
+	for _, key := range sortedKeys(nodeMap) {
+		value := nodeMap[key]
+		switch key {
+		case "description":
+			continue
+		case "properties":
+			properties, _ := value.(map[string]interface{})
+			convertedProperties := map[string]interface{}{}
+			for _, name := range sortedKeys(properties) {
+				convertedProperties[name] = convertSchema(properties[name], file, kind, joinFieldPath(fieldPath, name), conversionReport)
+			}
+			converted[key] = convertedProperties
+		case "items":
+			converted[key] = convertSchema(value, file, kind, fieldPath+"[]", conversionReport)
+		case "additionalProperties":
+			converted[key] = convertSchema(value, file, kind, fieldPath+".*", conversionReport)
+		default:
+			converted[key] = value
+		}
+	}
+	if converted["type"] == "array" {
+		addMergeKey(converted, file, kind, fieldPath, conversionReport)
+	}
+	return converted
+}
+
+// Sets the merge key of a list of objects from its list map keys, or guesses it from a name field
+func addMergeKey(list map[string]interface{}, file, kind, fieldPath string, conversionReport *report.Report) {
+	items, _ := list["items"].(map[string]interface{})
+	if items["type"] != "object" || list[mergeKeyExtension] != nil {
+		return
+	}
+	switch list[listTypeExtension] {
+	case "atomic", "set":
+		return
+	case "map":
+		mapKeys, _ := list[listMapKeyExtension].([]interface{})
+		if len(mapKeys) == 1 {
+			list[mergeKeyExtension] = mapKeys[0]
+			list[strategyExtension] = "merge"
+			return
+		}
+		conversionReport.Warnf(file, "%s %s: the list has the map keys %v, strategic merge supports a single key, the list is replaced by patches",
+			kind, fieldPath, mapKeys)
+		return
+	}
+
+	properties, _ := items["properties"].(map[string]interface{})
+	if _, ok := properties[guessedMergeKey]; ok {
+		list[mergeKeyExtension] = guessedMergeKey
+		list[strategyExtension] = "merge"
+		conversionReport.Infof(file, "%s %s: merge key guessed from the list item fields: %s", kind, fieldPath, guessedMergeKey)
+		return
+	}
+	conversionReport.Warnf(file, "%s %s: no merge key found, the list is replaced by patches. Set %s to one of the fields %s if needed",
+		kind, fieldPath, mergeKeyExtension, strings.Join(sortedKeys(properties), ", "))
+}
+
+func sortedKeys(content map[string]interface{}) (keys []string) {
+	for key := range content {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinFieldPath(fieldPath, name string) string {
+	if fieldPath == "" {
+		return name
+	}
+	return fieldPath + "." + name
+}
@@ -0,0 +1,71 @@
+package schemas
+
+import (
+	"testing"
+
+	"github.com/test-network-function/pgt2acm/packages/report"
+)
+
+// Returns a list of objects with the given item fields and extensions
+func objectList(fields []string, extensions map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, field := range fields {
+		properties[field] = map[string]interface{}{"type": "string"}
+	}
+	list := map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "object", "properties": properties}}
+	for key, value := range extensions {
+		list[key] = value
+	}
+	return list
+}
+
+func TestAddMergeKey(t *testing.T) {
+	tests := []struct {
+		name         string
+		list         map[string]interface{}
+		wantMergeKey interface{}
+		wantLevel    report.Level
+	}{
+		{
+			name:         "single list map key",
+			list:         objectList([]string{"host", "name"}, map[string]interface{}{listTypeExtension: "map", listMapKeyExtension: []interface{}{"host"}}),
+			wantMergeKey: "host",
+		},
+		{
+			name:         "guessed name",
+			list:         objectList([]string{"interface", "name"}, nil),
+			wantMergeKey: guessedMergeKey,
+			wantLevel:    report.LevelInfo,
+		},
+		{
+			name:      "no name field",
+			list:      objectList([]string{"nodeLabel", "nodeName"}, nil),
+			wantLevel: report.LevelWarning,
+		},
+		{
+			name:      "several list map keys",
+			list:      objectList([]string{"host", "name", "port"}, map[string]interface{}{listTypeExtension: "map", listMapKeyExtension: []interface{}{"host", "port"}}),
+			wantLevel: report.LevelWarning,
+		},
+		{
+			name: "atomic list",
+			list: objectList([]string{"name"}, map[string]interface{}{listTypeExtension: "atomic"}),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conversionReport := &report.Report{}
+			addMergeKey(test.list, "crd.yaml", "Widget", "spec.items", conversionReport)
+			if test.list[mergeKeyExtension] != test.wantMergeKey {
+				t.Errorf("merge key = %v, want %v", test.list[mergeKeyExtension], test.wantMergeKey)
+			}
+			var level report.Level
+			if len(conversionReport.Entries) > 0 {
+				level = conversionReport.Entries[0].Level
+			}
+			if len(conversionReport.Entries) > 1 || level != test.wantLevel {
+				t.Errorf("report entries = %v, want one %q entry", conversionReport.Entries, test.wantLevel)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package schemas
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/test-network-function/pgt2acm/packages/report"
+)
+
+const definitionRefPrefix = "#/definitions/"
+
+// Prune returns the definitions of the manifest kinds from a full schema, such as the output of
+// kustomize openapi fetch, together with all the definitions they refer to. Descriptions are
+// dropped and the merge keys missing from the lists of objects are taken from the schema library,
+// or added as with FromCRDFiles, the guesses are reported for review
+func Prune(fullSchema string, manifests []map[string]interface{}, conversionReport *report.Report) (schemaJSON []byte, err error) {
+	content, err := os.ReadFile(fullSchema)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %s, err: %s ", fullSchema, err)
+	}
+	full := schemaFile{}
+	err = json.Unmarshal(content, &full)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse schema: %s, err: %s", fullSchema, err)
+	}
+
+	library, err := getLibraryDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	libraryByGVK := map[string]interface{}{}
+	for _, definition := range library {
+		for _, gvk := range definitionGVKs(definition) {
+			libraryByGVK[gvk] = definition
+		}
+	}
+
+	definitionsByGVK := map[string]string{}
+	for _, name := range sortedKeys(full.Definitions) {
+		for _, gvk := range definitionGVKs(full.Definitions[name]) {
+			definitionsByGVK[gvk] = name
+		}
+	}
+	var pending []string
+	for _, manifest := range manifests {
+		gvk := manifestGVK(manifest)
+		name, ok := definitionsByGVK[gvk]
+		if !ok {
+			conversionReport.Warnf(fullSchema, "no definition found for %s", gvk)
+			continue
+		}
+		pending = append(pending, name)
+	}
+
+	// Adds the definitions referred to transitively
+	pruned := schemaFile{Definitions: map[string]interface{}{}}
+	for len(pending) != 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if _, ok := pruned.Definitions[name]; ok {
+			continue
+		}
+		definition, ok := full.Definitions[name]
+		if !ok {
+			conversionReport.Warnf(fullSchema, "the definition %s is referred to but not defined", name)
+			continue
+		}
+		for _, gvk := range definitionGVKs(definition) {
+			if libraryDefinition, ok := libraryByGVK[gvk]; ok {
+				definition = copyMergeKeys(definition, libraryDefinition)
+			}
+		}
+		pruned.Definitions[name] = convertSchema(definition, fullSchema, name, "", conversionReport)
+		pending = append(pending, definitionRefs(definition)...)
+	}
+	if len(pruned.Definitions) == 0 {
+		return nil, fmt.Errorf("no definition found in %s for the patched kinds", fullSchema)
+	}
+	return json.MarshalIndent(pruned, "", "  ")
+}
+
+// Returns a copy of a schema node with the merge keys of the same fields in the library definition
+func copyMergeKeys(node, libraryNode interface{}) interface{} {
+	nodeMap, ok := node.(map[string]interface{})
+	libraryMap, libraryOk := libraryNode.(map[string]interface{})
+	if !ok || !libraryOk {
+		return node
+	}
+	copied := map[string]interface{}{}
+	for key, value := range nodeMap {
+		copied[key] = value
+	}
+	for _, key := range []string{mergeKeyExtension, strategyExtension} {
+		if _, ok := copied[key]; !ok && libraryMap[key] != nil {
+			copied[key] = libraryMap[key]
+		}
+	}
+	if properties, ok := nodeMap["properties"].(map[string]interface{}); ok {
+		libraryProperties, _ := libraryMap["properties"].(map[string]interface{})
+		copiedProperties := map[string]interface{}{}
+		for name, property := range properties {
+			copiedProperties[name] = copyMergeKeys(property, libraryProperties[name])
+		}
+		copied["properties"] = copiedProperties
+	}
+	for _, key := range []string{"items", "additionalProperties"} {
+		if _, ok := nodeMap[key]; ok {
+			copied[key] = copyMergeKeys(nodeMap[key], libraryMap[key])
+		}
+	}
+	return copied
+}
+
+// Returns the names of the definitions referred to by a schema node
+func definitionRefs(node interface{}) (refs []string) {
+	switch typedNode := node.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(typedNode) {
+			if ref, ok := typedNode[key].(string); ok && key == "$ref" && strings.HasPrefix(ref, definitionRefPrefix) {
+				refs = append(refs, strings.TrimPrefix(ref, definitionRefPrefix))
+				continue
+			}
+			refs = append(refs, definitionRefs(typedNode[key])...)
+		}
+	case []interface{}:
+		for _, item := range typedNode {
+			refs = append(refs, definitionRefs(item)...)
+		}
+	}
+	return refs
+}
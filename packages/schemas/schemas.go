@@ -0,0 +1,170 @@
+// Package schemas provides the Kustomize OpenAPI schemas used to apply strategic merge patches on
+// the custom resources commonly patched by ZTP templates. Kustomize needs these schemas to merge
+// lists of objects by key instead of replacing them.
+package schemas
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/test-network-function/pgt2acm/packages/stringhelper"
+)
+
+// The version of the operators the schema library is written for
+const LibraryVersion = "openshift-4.16"
+
+const gvkExtension = "x-kubernetes-group-version-kind"
+
+//go:embed openshift-4.16/*.json
+var library embed.FS
+
+// A Kustomize OpenAPI schema
+type schemaFile struct {
+	Definitions map[string]interface{} `json:"definitions"`
+}
+
+var (
+	libraryDefinitions map[string]interface{}
+	// User schema definitions, indexed by schema path
+	userDefinitions = map[string]map[string]interface{}{}
+	// Schemas already built, indexed by schema ID
+	builtSchemas = map[string][]byte{}
+	cacheLock    sync.Mutex
+)
+
+// Schema returns the schema to use to patch the manifests and an ID identifying it. The schema
+// contains the library definitions of the manifest kinds and all the definitions of the optional
+// user schema. A user definition overrides the library definition of the same kind. An empty
+// schema is returned if no definition applies, Kustomize then uses its built-in schema. Note that
+// Kustomize does not use its built-in schema when a schema is provided
+func Schema(manifests []map[string]interface{}, userSchema string) (schemaID string, schemaJSON []byte, err error) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	definitions, err := getUserDefinitions(userSchema)
+	if err != nil {
+		return "", nil, err
+	}
+	userKinds := map[string]bool{}
+	for _, definition := range definitions {
+		for _, gvk := range definitionGVKs(definition) {
+			userKinds[gvk] = true
+		}
+	}
+
+	library, err := getLibraryDefinitions()
+	if err != nil {
+		return "", nil, err
+	}
+	var selected []string
+	for _, manifest := range manifests {
+		gvk := manifestGVK(manifest)
+		if userKinds[gvk] {
+			continue
+		}
+		for name, definition := range library {
+			if stringhelper.StringInSlice(definitionGVKs(definition), gvk, false) && !stringhelper.StringInSlice(selected, name, false) {
+				selected = append(selected, name)
+			}
+		}
+	}
+	if userSchema == "" && len(selected) == 0 {
+		return "", nil, nil
+	}
+
+	sort.Strings(selected)
+	schemaID = userSchema + "|" + strings.Join(selected, ",")
+	if schemaJSON, ok := builtSchemas[schemaID]; ok {
+		return schemaID, schemaJSON, nil
+	}
+	schema := schemaFile{Definitions: map[string]interface{}{}}
+	for _, name := range selected {
+		schema.Definitions[name] = library[name]
+	}
+	for name, definition := range definitions {
+		schema.Definitions[name] = definition
+	}
+	schemaJSON, err = json.Marshal(schema)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not marshall schema, err: %s", err)
+	}
+	builtSchemas[schemaID] = schemaJSON
+	return schemaID, schemaJSON, nil
+}
+
+func getLibraryDefinitions() (definitions map[string]interface{}, err error) {
+	if libraryDefinitions != nil {
+		return libraryDefinitions, nil
+	}
+	entries, err := library.ReadDir(LibraryVersion)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the schema library, err: %s", err)
+	}
+	definitions = map[string]interface{}{}
+	for _, entry := range entries {
+		content, err := library.ReadFile(path.Join(LibraryVersion, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("could not read the schema library, err: %s", err)
+		}
+		schema := schemaFile{}
+		err = json.Unmarshal(content, &schema)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the library schema %s, err: %s", entry.Name(), err)
+		}
+		for name, definition := range schema.Definitions {
+			definitions[name] = definition
+		}
+	}
+	libraryDefinitions = definitions
+	return definitions, nil
+}
+
+func getUserDefinitions(userSchema string) (definitions map[string]interface{}, err error) {
+	if userSchema == "" {
+		return nil, nil
+	}
+	if definitions, ok := userDefinitions[userSchema]; ok {
+		return definitions, nil
+	}
+	content, err := os.ReadFile(userSchema)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %s, err: %s ", userSchema, err)
+	}
+	schema := schemaFile{}
+	err = json.Unmarshal(content, &schema)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse schema: %s, err: %s", userSchema, err)
+	}
+	userDefinitions[userSchema] = schema.Definitions
+	return schema.Definitions, nil
+}
+
+// Returns the group/version/kind strings of a definition, from its x-kubernetes-group-version-kind extension
+func definitionGVKs(definition interface{}) (gvks []string) {
+	definitionMap, _ := definition.(map[string]interface{})
+	gvkList, _ := definitionMap[gvkExtension].([]interface{})
+	for _, gvk := range gvkList {
+		gvkMap, _ := gvk.(map[string]interface{})
+		group, _ := gvkMap["group"].(string)
+		version, _ := gvkMap["version"].(string)
+		kind, _ := gvkMap["kind"].(string)
+		apiVersion := version
+		if group != "" {
+			apiVersion = group + "/" + version
+		}
+		gvks = append(gvks, apiVersion+"/"+kind)
+	}
+	return gvks
+}
+
+func manifestGVK(manifest map[string]interface{}) string {
+	apiVersion, _ := manifest["apiVersion"].(string)
+	kind, _ := manifest["kind"].(string)
+	return apiVersion + "/" + kind
+}
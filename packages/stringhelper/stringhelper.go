@@ -27,3 +27,26 @@ func IsNumber(s string) bool {
 	}
 	return true
 }
+
+// EditDistance returns the Levenshtein distance between two strings, the number of single character
+// insertions, deletions or substitutions changing one string into the other.
+func EditDistance(a, b string) int {
+	first, second := []rune(a), []rune(b)
+	previous := make([]int, len(second)+1)
+	current := make([]int, len(second)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(first); i++ {
+		current[0] = i
+		for j := 1; j <= len(second); j++ {
+			substitution := previous[j-1]
+			if first[i-1] != second[j-1] {
+				substitution++
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, substitution)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(second)]
+}
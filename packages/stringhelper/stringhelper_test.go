@@ -0,0 +1,25 @@
+package stringhelper
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"slave", "slave", 0},
+		{"", "slave", 5},
+		{"slave", "", 5},
+		{"du-ptp-slav", "du-ptp-slave", 1},
+		{"defualt", "default", 2},
+		{"slave1", "slave2", 1},
+		{"kitten", "sitting", 3},
+		{"é", "e", 1},
+	}
+	for _, test := range tests {
+		if got := EditDistance(test.a, test.b); got != test.want {
+			t.Errorf("EditDistance(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/test-network-function/pgt2acm/packages/acmformat"
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"github.com/test-network-function/pgt2acm/packages/report"
+	"gopkg.in/yaml.v3"
+)
+
+// Matches a field path segment with optional list indexes, such as "pages[0]"
+var fieldPathSegmentRegexp = regexp.MustCompile(`^([^\[]*)((?:\[\d+\])*)$`)
+
+// Records the YAML nodes of a PGT document and of its source files, giving the positions of the
+// conversion messages and errors in the input file
+func registerPGTNodes(options *conversionOptions, document *yaml.Node, policyGenTemp *pgtformat.PolicyGenTemplate) {
+	root := document
+	if root.Kind == yaml.DocumentNode && len(root.Content) != 0 {
+		root = root.Content[0]
+	}
+	options.pgtNodes[policyGenTemp] = root
+	for srcFileIndex := range policyGenTemp.Spec.SourceFiles {
+		options.sourceFileNodes[&policyGenTemp.Spec.SourceFiles[srcFileIndex]] = sourceFileNode(root, srcFileIndex)
+	}
+}
+
+// Returns the node of a source file entry in the node of a PGT document, nil if not found
+func sourceFileNode(pgtNode *yaml.Node, srcFileIndex int) *yaml.Node {
+	if pgtNode.Kind == yaml.DocumentNode && len(pgtNode.Content) != 0 {
+		pgtNode = pgtNode.Content[0]
+	}
+	sourceFilesNode := mappingValue(mappingValue(pgtNode, "spec"), "sourceFiles")
+	if sourceFilesNode == nil || sourceFilesNode.Kind != yaml.SequenceNode || srcFileIndex >= len(sourceFilesNode.Content) {
+		return nil
+	}
+	return sourceFilesNode.Content[srcFileIndex]
+}
+
+// Returns the position of a PGT document in its input file
+func pgtPosition(options *conversionOptions, inputFile string, policyGenTemp *pgtformat.PolicyGenTemplate) report.Position {
+	return nodePosition(inputFile, options.pgtNodes[policyGenTemp])
+}
+
+// Returns the position of a source file entry in the PGT input file or, when the field path is not
+// empty, the position of the field in the entry, such as spec.profile[0].interface. The position of
+// the closest parent field is returned when the field is not in the entry
+func sourceFilePosition(options *conversionOptions, inputFile string, sourceFile *pgtformat.SourceFile, fieldPath string) report.Position {
+	node := options.sourceFileNodes[sourceFile]
+	position := nodePosition(inputFile, node)
+	fieldPath = strings.TrimPrefix(strings.TrimPrefix(fieldPath, "$"), ".")
+	if fieldPath == "" {
+		return position
+	}
+	for _, segment := range strings.Split(fieldPath, ".") {
+		matches := fieldPathSegmentRegexp.FindStringSubmatch(segment)
+		if matches == nil {
+			return position
+		}
+		keyNode, valueNode := mappingEntry(node, matches[1])
+		if keyNode == nil {
+			return position
+		}
+		position, node = nodePosition(inputFile, keyNode), valueNode
+		for _, index := range strings.Split(strings.Trim(matches[2], "[]"), "][") {
+			if index == "" {
+				continue
+			}
+			itemIndex, err := strconv.Atoi(index)
+			if err != nil || node.Kind != yaml.SequenceNode || itemIndex >= len(node.Content) {
+				return position
+			}
+			node = node.Content[itemIndex]
+			position = nodePosition(inputFile, node)
+		}
+	}
+	return position
+}
+
+// Returns the position of a policy in the PGT input file: the position of the policyName of its first
+// source file
+func policyPosition(options *conversionOptions, inputFile string, policyGenTemp *pgtformat.PolicyGenTemplate, policy *acmformat.PolicyConfig) report.Position {
+	if len(policy.Manifests) == 0 || policy.Manifests[0].SourceFileIndex >= len(policyGenTemp.Spec.SourceFiles) {
+		return pgtPosition(options, inputFile, policyGenTemp)
+	}
+	return sourceFilePosition(options, inputFile, &policyGenTemp.Spec.SourceFiles[policy.Manifests[0].SourceFileIndex], "policyName")
+}
+
+// Returns the position of a node in a file, the whole file if the node is not known
+func nodePosition(file string, node *yaml.Node) report.Position {
+	if node == nil {
+		return report.Position{File: file}
+	}
+	return report.Position{File: file, Line: node.Line, Column: node.Column}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"github.com/test-network-function/pgt2acm/packages/report"
+	"gopkg.in/yaml.v3"
+)
+
+// A PGT whose source file has an invalid JSON patch annotation, at line 12
+const invalidJSONPatchPGT = `apiVersion: ran.openshift.io/v1
+kind: PolicyGenTemplate
+metadata:
+  name: pgt
+  namespace: ztp-common
+spec:
+  wrapInPolicy: %t
+  sourceFiles:
+    - fileName: ConfigMapGeneric.yaml
+      policyName: config-policy
+      metadata:
+        annotations:
+          pgt2acm.openshift.io/json-patch: "{"
+`
+
+// Converts the PGT with the invalid JSON patch annotation and returns the conversion error
+func convertInvalidJSONPatchPGT(t *testing.T, wrapInPolicy bool) (inputFile string, err error) {
+	t.Helper()
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	inputFile = filepath.Join(inputDir, "pgt.yaml")
+	err = os.WriteFile(inputFile, []byte(fmt.Sprintf(invalidJSONPatchPGT, wrapInPolicy)), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(filepath.Join(outputDir, sourceCrPrefix), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceCR, err := os.ReadFile(filepath.Join("test", "init-source-crs", "ConfigMapGeneric.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(outputDir, sourceCrPrefix, "ConfigMapGeneric.yaml"), sourceCR, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := &conversionOptions{inputDir: inputDir, outputDir: outputDir, report: &report.Report{}, renderedSourceCRs: map[string]string{},
+		pgtNodes: map[*pgtformat.PolicyGenTemplate]*yaml.Node{}, sourceFileNodes: map[*pgtformat.SourceFile]*yaml.Node{}}
+
+	_, err = convertAllPGTFiles(options, []string{inputFile})
+	return inputFile, err
+}
+
+func TestConversionErrorPosition(t *testing.T) {
+	tests := []struct {
+		wrapInPolicy bool
+		want         string
+	}{
+		{true, ":12:9: failed to convert PGT to ACMGen: could not convert PolicyGenTemplate pgt: could not apply the JSON patches: source-crs/ConfigMapGeneric.yaml: "},
+		{false, ":12:9: failed to convert PGT to ACMGen: could not convert PolicyGenTemplate pgt: source file ConfigMapGeneric.yaml: "},
+	}
+	for _, test := range tests {
+		inputFile, err := convertInvalidJSONPatchPGT(t, test.wrapInPolicy)
+		if err == nil {
+			t.Fatalf("convertAllPGTFiles() with wrapInPolicy %t succeeded, want an error", test.wrapInPolicy)
+		}
+		// The position starts the message, followed by the context and the error of the annotation
+		want := inputFile + test.want + "could not parse the pgt2acm.openshift.io/json-patch annotation of source file ConfigMapGeneric.yaml, err: "
+		if !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("convertAllPGTFiles() with wrapInPolicy %t error = %q, want the prefix %q", test.wrapInPolicy, err, want)
+		}
+	}
+}
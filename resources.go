@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/test-network-function/pgt2acm/packages/fileutils"
+	"github.com/test-network-function/pgt2acm/packages/patches"
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"github.com/test-network-function/pgt2acm/packages/report"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type resourcesKustomization struct {
+	Resources []string `yaml:"resources"`
+}
+
+// Converts a PGT with wrapInPolicy set to false. Such a PGT generates the patched source CRs instead of
+// policies, so it is converted to a plain Kustomize directory listing the patched source CRs as resources.
+// A source CR listed by several source files is written once per source file, suffixed with the name of
+// the patched resource. Source files generating the same resource are rejected, Kustomize could not build it
+func convertPGTtoResources(options *conversionOptions, inputFile string, policyGenTemp *pgtformat.PolicyGenTemplate, resourcesDir string) (err error) {
+	if options.checkMerge {
+		checkMergeEquivalence(options, inputFile, policyGenTemp)
+	}
+	fileNameCount := map[string]int{}
+	for srcFileIndex := range policyGenTemp.Spec.SourceFiles {
+		fileNameCount[policyGenTemp.Spec.SourceFiles[srcFileIndex].FileName]++
+	}
+	kustomization := resourcesKustomization{}
+	// The source file generating each resource, indexed by resource identity
+	generatedBy := map[string]*pgtformat.SourceFile{}
+	for srcFileIndex := range policyGenTemp.Spec.SourceFiles {
+		sourceFile := &policyGenTemp.Spec.SourceFiles[srcFileIndex]
+		var manifests []map[string]interface{}
+		manifests, err = renderSourceFile(options, inputFile, policyGenTemp, sourceFile)
+		if err != nil {
+			return report.Wrapf(err, sourceFilePosition(options, inputFile, sourceFile, ""), "source file %s", sourceFile.FileName)
+		}
+		for _, manifest := range manifests {
+			if previous, ok := generatedBy[manifestID(manifest)]; ok {
+				return report.Errorf(sourceFilePosition(options, inputFile, sourceFile, ""), "source file %s: the resource %s is already generated by the source file at %s",
+					sourceFile.FileName, manifestID(manifest), sourceFilePosition(options, inputFile, previous, ""))
+			}
+			generatedBy[manifestID(manifest)] = sourceFile
+		}
+		resourcePath := sourceFile.FileName
+		if fileNameCount[sourceFile.FileName] > 1 && len(manifests) != 0 {
+			resourcePath = strings.TrimSuffix(resourcePath, ".yaml") + "-" + patches.StringField(manifests[0], "metadata", "name") + ".yaml"
+		}
+		err = writeManifestsToFile(manifests, filepath.Join(resourcesDir, resourcePath))
+		if err != nil {
+			return err
+		}
+		kustomization.Resources = append(kustomization.Resources, resourcePath)
+	}
+
+	var content bytes.Buffer
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&kustomization)
+	if err != nil {
+		return fmt.Errorf("could not marshall kustomization, err: %s", err)
+	}
+	err = os.MkdirAll(resourcesDir, fileutils.DefaultDirWritePermissions)
+	if err != nil {
+		return err
+	}
+	kustomizationPath := filepath.Join(resourcesDir, fileutils.KustomizationFileName)
+	err = os.WriteFile(kustomizationPath, content.Bytes(), fileutils.DefaultFileWritePermissions)
+	if err != nil {
+		return fmt.Errorf("error writing to file: %s, err: %s", kustomizationPath, err)
+	}
+	fmt.Printf("Wrote resources kustomization: %s\n", kustomizationPath)
+	options.report.InfofAt(pgtPosition(options, inputFile, policyGenTemp), "PolicyGenTemplate %s: wrapInPolicy is false, converted to the resources directory %s", policyGenTemp.Metadata.Name, resourcesDir)
+	return nil
+}
+
+// Renders the $mcp keyword in a source CR and applies the patches defined by the PGT source file on it
+func renderSourceFile(options *conversionOptions, inputFile string, policyGenTemp *pgtformat.PolicyGenTemplate, sourceFile *pgtformat.SourceFile) (manifests []map[string]interface{}, err error) {
+	sourceCRPath := filepath.Join(options.outputDir, sourceCrPrefix, sourceFile.FileName)
+	renderedPath, err := renderMCPLines(options, sourceCRPath, policyGenTemp.Spec.Mcp)
+	if err != nil {
+		return manifests, fmt.Errorf("cannot render MCP lines, err:%s", err)
+	}
+	manifests, err = patches.UnmarshalManifestFile(renderedPath)
+	if err != nil {
+		return manifests, fmt.Errorf("could not unmarshall manifest: %s, err: %s", renderedPath, err)
+	}
+
+	jsonPatches, err := sourceFileJSONPatches(options, inputFile, manifests, sourceFile)
+	if err != nil {
+		return manifests, err
+	}
+	patch, hasPatch := convertSourceFileToPatch(sourceFile)
+	if !hasPatch && len(jsonPatches) == 0 {
+		return manifests, nil
+	}
+	patcher := patches.ManifestPatcher{Manifests: manifests, JSONPatches: jsonPatches}
+	if hasPatch {
+		patcher.Patches = []map[string]interface{}{patch}
+		if len(manifests) == 1 {
+			renameSourceCR(manifests[0], patch)
+		}
+	}
+	const errTemplate = `failed to process the manifest at "%s": %w`
+
+	err = patcher.Validate()
+	if err != nil {
+		return manifests, fmt.Errorf(errTemplate, renderedPath, err)
+	}
+	// The fixed source CR is part of the patched resources, it is not copied
+	manifests, _, err = applySourceFilePatches(options, inputFile, sourceFile, &patcher, "")
+	if err != nil {
+		return manifests, fmt.Errorf(errTemplate, renderedPath, err)
+	}
+	return manifests, nil
+}
+
+// Renames a single-document source CR to the name and namespace overridden by the patch, as PGT merges
+// the metadata of the source file into the source CR
+func renameSourceCR(manifest, patch map[string]interface{}) {
+	for _, field := range [][]string{{"metadata", "name"}, {"metadata", "namespace"}} {
+		if value := patches.StringField(patch, field...); value != "" {
+			_ = unstructured.SetNestedField(manifest, value, field...)
+		}
+	}
+}
+
+// Writes manifests to a multi-document YAML file
+func writeManifestsToFile(manifests []map[string]interface{}, outputFile string) (err error) {
+	var content bytes.Buffer
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	for _, manifest := range manifests {
+		err = encoder.Encode(manifest)
+		if err != nil {
+			return fmt.Errorf("could not marshall manifest, err: %s", err)
+		}
+	}
+	err = encoder.Close()
+	if err != nil {
+		return fmt.Errorf("could not marshall manifest, err: %s", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(outputFile), fileutils.DefaultDirWritePermissions)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(outputFile, content.Bytes(), fileutils.DefaultFileWritePermissions)
+	if err != nil {
+		return fmt.Errorf("error writing to file: %s, err: %s", outputFile, err)
+	}
+	fmt.Printf("Wrote patched resource: %s\n", outputFile)
+	return nil
+}
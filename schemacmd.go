@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/test-network-function/pgt2acm/packages/fileutils"
+	"github.com/test-network-function/pgt2acm/packages/patches"
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"github.com/test-network-function/pgt2acm/packages/report"
+	"github.com/test-network-function/pgt2acm/packages/schemas"
+	"gopkg.in/yaml.v3"
+)
+
+const schemaCommand = "schema"
+
+// Runs the schema subcommand, generating a Kustomize schema from CustomResourceDefinition files:
+//
+//	pgt2acm schema -i <CRD file or directory> -o <schema file>
+func runSchemaCommand(args []string) (err error) {
+	flags := flag.NewFlagSet(schemaCommand, flag.ExitOnError)
+	// Defines the input CRD directory or file
+	inputPath := flags.String("i", "", "the CustomResourceDefinition input file or directory, for instance a source-crs or operator bundle directory")
+	// Defines the generated schema file
+	outputFile := flags.String("o", "", "the generated Kustomize schema file")
+	err = flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *inputPath == "" || *outputFile == "" {
+		flags.Usage()
+		return errors.New("the -i and -o options are required")
+	}
+
+	files, err := fileutils.GetAllYAMLFilesInPath(*inputPath)
+	if err != nil {
+		return fmt.Errorf("could not get file list, err: %s", err)
+	}
+	conversionReport := report.Report{}
+	schemaJSON, err := schemas.FromCRDFiles(files, &conversionReport)
+	conversionReport.Print(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	return writeSchema(schemaJSON, *outputFile)
+}
+
+const pruneSchemaCommand = "prune-schema"
+
+// Runs the prune-schema subcommand, keeping only the definitions of the kinds patched by PGTs from a
+// full schema:
+//
+//	pgt2acm prune-schema -i <PGT file or directory> -c <source-crs directory> -s <full schema> -o <schema file>
+func runPruneSchemaCommand(args []string) (err error) {
+	flags := flag.NewFlagSet(pruneSchemaCommand, flag.ExitOnError)
+	// Defines the input PGT directory or file
+	inputPath := flags.String("i", "", "the PGT input file or directory")
+	// Defines the source-crs directory
+	sourceCRs := flags.String("c", "", "the source-crs directory of the PGTs, defaults to the source-crs subdirectory of the input directory")
+	// Defines the full schema
+	fullSchema := flags.String("s", "", "the full schema, for instance the output of kustomize openapi fetch")
+	// Defines the generated schema file
+	outputFile := flags.String("o", "", "the generated Kustomize schema file")
+	err = flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *inputPath == "" || *fullSchema == "" || *outputFile == "" {
+		flags.Usage()
+		return errors.New("the -i, -s and -o options are required")
+	}
+	if *sourceCRs == "" {
+		*sourceCRs = filepath.Join(*inputPath, sourceCrPrefix)
+	}
+
+	conversionReport := report.Report{}
+	manifests, err := getPatchedManifests(*inputPath, *sourceCRs, &conversionReport)
+	if err != nil {
+		conversionReport.Print(os.Stdout)
+		return err
+	}
+	schemaJSON, err := schemas.Prune(*fullSchema, manifests, &conversionReport)
+	conversionReport.Print(os.Stdout)
+	if err != nil {
+		return err
+	}
+	return writeSchema(schemaJSON, *outputFile)
+}
+
+// Returns the source CR manifests receiving patches from the PGTs of the input path
+func getPatchedManifests(inputPath, sourceCRs string, conversionReport *report.Report) (manifests []map[string]interface{}, err error) {
+	files, err := fileutils.GetAllYAMLFilesInPath(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not get file list, err: %s", err)
+	}
+	seenSourceCRs := map[string]bool{}
+	for _, file := range files {
+		var documents []*yaml.Node
+		documents, err = readYAMLDocuments(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, document := range documents {
+			policyGenTemp := pgtformat.PolicyGenTemplate{}
+			if document.Decode(&policyGenTemp) != nil || policyGenTemp.Kind != policyGenTemplateKind {
+				continue
+			}
+			for srcFileIndex := range policyGenTemp.Spec.SourceFiles {
+				sourceFile := &policyGenTemp.Spec.SourceFiles[srcFileIndex]
+				sourceCRPath := filepath.Join(sourceCRs, sourceFile.FileName)
+				_, hasPatch := convertSourceFileToPatch(sourceFile)
+				if !hasPatch || seenSourceCRs[sourceCRPath] || isStatusCheck(sourceCRPath, sourceFile) {
+					continue
+				}
+				seenSourceCRs[sourceCRPath] = true
+				var sourceCRManifests []map[string]interface{}
+				sourceCRManifests, err = patches.UnmarshalManifestFile(sourceCRPath)
+				if err != nil {
+					conversionReport.WarnfAt(nodePosition(file, sourceFileNode(document, srcFileIndex)), "source file %s: could not read the source CR, err: %s", sourceFile.FileName, err)
+					continue
+				}
+				manifests = append(manifests, sourceCRManifests...)
+			}
+		}
+	}
+	return manifests, nil
+}
+
+// Writes a generated schema to a file
+func writeSchema(schemaJSON []byte, outputFile string) (err error) {
+	err = os.MkdirAll(filepath.Dir(outputFile), fileutils.DefaultDirWritePermissions)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(outputFile, append(schemaJSON, '\n'), fileutils.DefaultFileWritePermissions)
+	if err != nil {
+		return fmt.Errorf("error writing to file: %s, err: %s", outputFile, err)
+	}
+	fmt.Printf("Wrote schema: %s\n", outputFile)
+	return nil
+}
@@ -1,10 +1,43 @@
 #!/bin/bash
-rm -rf test/acmgen-output
-mkdir test/acmgen-output
+rm -rf test/acmgen-output test/schema-output test/cases-output
+mkdir test/acmgen-output test/schema-output
 cp -r test/init-source-crs test/acmgen-output/source-crs
-./pgt2acm -i test/pgt-input -o test/acmgen-output -s test/newptpconfig-schema.json -k PtpConfig -n ""
+# The conversion reports are saved next to the generated files
+report_lines() {
+	grep -E '^[^ ]+: (info|warning): '
+}
+./pgt2acm -i test/pgt-input -o test/acmgen-output -k PtpConfig -n "" -split-waves -wave-dependencies -check-merge -replace-schemaless |
+	report_lines >test/acmgen-output/report.txt
+./pgt2acm schema -i test/crds -o test/schema-output/ptpconfig-schema.json | report_lines >test/schema-output/ptpconfig-schema-report.txt
+./pgt2acm prune-schema -i test/pgt-input -c test/init-source-crs -s test/openapi-dump.json -o test/schema-output/pruned-schema.json |
+	report_lines >test/schema-output/pruned-schema-report.txt
 
-if diff -r test/acmgen-output test/acmgen-expected-output; then
+# Converts an input directory with the given options to test/cases-output/<case>. The conversion
+# report is saved as report.txt in the output directory, and the source CRs left unchanged are removed
+run_case() {
+	local name=$1 input=$2 output=test/cases-output/$1
+	shift 2
+	mkdir -p "$output"
+	cp -r test/init-source-crs "$output"/source-crs
+	./pgt2acm -i "$input" -o "$output" "$@" | report_lines >"$output"/report.txt
+	(cd test/init-source-crs && find . -type f) | while read -r sourceCR; do
+		if cmp -s test/init-source-crs/"$sourceCR" "$output"/source-crs/"$sourceCR"; then
+			rm "$output"/source-crs/"$sourceCR"
+		fi
+	done
+	find "$output"/source-crs -type d -empty -delete
+}
+
+run_case placement-workaround test/pgt-input -k PtpConfig -n "" -w
+run_case placement-config test/pgt-input -k PtpConfig -n "" -placement-config test/placement-config.yaml
+run_case pre-render-auto test/pgt-input -k auto -n ""
+run_case fix-suggestion test/fix-input -k PtpConfig,ConfigMap -n ""
+run_case fix-patch test/fix-input -k PtpConfig,ConfigMap -n "" -fix patch
+run_case fix-source-cr test/fix-input -k PtpConfig,ConfigMap -n "" -fix source-cr -check-merge
+run_case ns-bindings test/ns-input -k PtpConfig -cluster-sets " global, canary,,"
+
+if diff -r test/acmgen-output test/acmgen-expected-output && diff -r test/schema-output test/schema-expected-output &&
+	diff -r test/cases-output test/cases-expected-output; then
 	echo "Test Passed"
 else
 	echo "Test failed"
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/test-network-function/pgt2acm/packages/acmformat"
+	"github.com/test-network-function/pgt2acm/packages/patches"
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"github.com/test-network-function/pgt2acm/packages/pgtmerge"
+)
+
+const (
+	statusCheckComplianceType    = "musthave"
+	statusCheckRemediationAction = "inform"
+)
+
+// Returns true if a source file only checks the status of its source CR, as the ZTP validator CRs
+// do: the source file overrides the status, or the source CR only defines a status, and nothing is
+// configured through spec, data or binaryData
+func isStatusCheck(sourceCRPath string, sourceFile *pgtformat.SourceFile) bool {
+	if len(sourceFile.Spec) != 0 || len(sourceFile.Data) != 0 || len(sourceFile.BinaryData) != 0 {
+		return false
+	}
+	if len(sourceFile.Status) != 0 {
+		return true
+	}
+	manifests, err := patches.UnmarshalManifestFile(sourceCRPath)
+	if err != nil || len(manifests) == 0 {
+		return false
+	}
+	for _, manifest := range manifests {
+		_, hasStatus := manifest["status"]
+		_, hasSpec := manifest["spec"]
+		_, hasData := manifest["data"]
+		if !hasStatus || hasSpec || hasData {
+			return false
+		}
+	}
+	return true
+}
+
+// Converts a status check source file to an inform only musthave manifest. A status can't be
+// checked with a Kustomize patch, so the overrides are merged the PGT way into a copy of the source
+// CR and the manifest refers to this copy, keeping the status in the object template
+func convertStatusCheck(options *conversionOptions, inputFile, policyName string, sourceFile *pgtformat.SourceFile, newManifest *acmformat.Manifest) (err error) {
+	if newManifest.ComplianceType != "" && newManifest.ComplianceType != statusCheckComplianceType ||
+		newManifest.RemediationAction != "" && newManifest.RemediationAction != statusCheckRemediationAction {
+		options.report.WarnfAt(sourceFilePosition(options, inputFile, sourceFile, ""), "source file %s: status checks are converted with complianceType %s and remediationAction %s, the source file settings are ignored",
+			sourceFile.FileName, statusCheckComplianceType, statusCheckRemediationAction)
+	}
+	newManifest.ComplianceType = statusCheckComplianceType
+	newManifest.RemediationAction = statusCheckRemediationAction
+
+	override, hasOverride := convertSourceFileToPatch(sourceFile)
+	if !hasOverride {
+		options.report.InfofAt(sourceFilePosition(options, inputFile, sourceFile, ""), "source file %s: converted to an inform only status check", sourceFile.FileName)
+		return nil
+	}
+
+	sourceCRPath := filepath.Join(options.outputDir, newManifest.Path)
+	manifests, err := patches.UnmarshalManifestFile(sourceCRPath)
+	if err != nil {
+		return err
+	}
+	manifests = mergeSourceFileOverrides(manifests, sourceFile, override)
+
+	// The status check copy is specific to the policy since the overrides may differ between policies
+	newManifest.Path = strings.TrimSuffix(newManifest.Path, ".yaml") + "-" + policyName + ".yaml"
+	err = writeManifestsToFile(manifests, filepath.Join(options.outputDir, newManifest.Path))
+	if err != nil {
+		return err
+	}
+	options.report.InfofAt(sourceFilePosition(options, inputFile, sourceFile, ""), "source file %s: converted to an inform only status check, the status overrides are merged into %s",
+		sourceFile.FileName, newManifest.Path)
+	return nil
+}
+
+// Merges the overrides of a source file into the source CR manifests the way PGT does. In a multi-document
+// source CR, the overrides are merged into the manifests with the overridden name, or into all the
+// manifests if the name is not overridden
+func mergeSourceFileOverrides(manifests []map[string]interface{}, sourceFile *pgtformat.SourceFile, override map[string]interface{}) (merged []map[string]interface{}) {
+	overrideName, _ := sourceFile.Metadata["name"].(string)
+	for _, manifest := range manifests {
+		manifestMetadata, _ := manifest["metadata"].(map[string]interface{})
+		if len(manifests) > 1 && overrideName != "" && manifestMetadata["name"] != overrideName {
+			merged = append(merged, manifest)
+			continue
+		}
+		merged = append(merged, pgtmerge.Merge(manifest, override))
+	}
+	return merged
+}
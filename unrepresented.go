@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"gopkg.in/yaml.v3"
+)
+
+// Reports the PGT fields that have no equivalent in the conversion output and are dropped: the fields
+// unknown to the PGT format, and for the PGTs wrapped in policies, the missing policy names and the
+// source file evaluation intervals overridden by another source file of the same policy
+func reportUnrepresentedFields(options *conversionOptions, inputFile string, policyGenTemp *pgtformat.PolicyGenTemplate) {
+	reportUnknownFields(options, inputFile, policyGenTemp.Metadata.Name, options.pgtNodes[policyGenTemp], "", reflect.TypeOf(*policyGenTemp))
+	if !policyGenTemp.Spec.WrapInPolicy {
+		return
+	}
+	pgtName := policyGenTemp.Metadata.Name
+	for srcFileIndex := range policyGenTemp.Spec.SourceFiles {
+		sourceFile := &policyGenTemp.Spec.SourceFiles[srcFileIndex]
+		if sourceFile.PolicyName == "" {
+			options.report.WarnfAt(sourceFilePosition(options, inputFile, sourceFile, ""), "PolicyGenTemplate %s: source file %s has no policyName, it is converted to the policy %s-", pgtName, sourceFile.FileName, pgtName)
+		}
+	}
+	reportOverriddenEvaluationIntervals(options, inputFile, policyGenTemp)
+}
+
+// Reports the fields of a PGT node missing from the PGT format, which the YAML decoding drops. The
+// fields of the format are followed down to the free-form maps, such as the source file spec
+func reportUnknownFields(options *conversionOptions, inputFile, pgtName string, node *yaml.Node, fieldPath string, format reflect.Type) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+	for index := 0; index+1 < len(node.Content); index += 2 {
+		keyNode, valueNode := node.Content[index], node.Content[index+1]
+		path := keyNode.Value
+		if fieldPath != "" {
+			path = fieldPath + "." + keyNode.Value
+		}
+		field, ok := yamlField(format, keyNode.Value)
+		if !ok {
+			options.report.WarnfAt(nodePosition(inputFile, keyNode), "PolicyGenTemplate %s: %s is not a PolicyGenTemplate field, it is dropped", pgtName, path)
+			continue
+		}
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			reportUnknownFields(options, inputFile, pgtName, valueNode, path, field.Type)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct && valueNode.Kind == yaml.SequenceNode:
+			for itemIndex, itemNode := range valueNode.Content {
+				reportUnknownFields(options, inputFile, pgtName, itemNode, fmt.Sprintf("%s[%d]", path, itemIndex), field.Type.Elem())
+			}
+		}
+	}
+}
+
+// Returns the field of a struct decoded from a YAML key
+func yamlField(format reflect.Type, key string) (field reflect.StructField, ok bool) {
+	for index := 0; index < format.NumField(); index++ {
+		field = format.Field(index)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if name == key {
+			return field, true
+		}
+	}
+	return field, false
+}
+
+// Reports the evaluation intervals of the source files overridden by the interval of a later source file
+// of the same policy, the ACM policy generator having a single evaluation interval per policy
+func reportOverriddenEvaluationIntervals(options *conversionOptions, inputFile string, policyGenTemp *pgtformat.PolicyGenTemplate) {
+	intervals := []struct {
+		name  string
+		value func(interval *pgtformat.EvaluationInterval) string
+	}{
+		{"compliant", func(interval *pgtformat.EvaluationInterval) string { return interval.Compliant }},
+		{"noncompliant", func(interval *pgtformat.EvaluationInterval) string { return interval.NonCompliant }},
+	}
+	sourceFiles := policyGenTemp.Spec.SourceFiles
+	for _, interval := range intervals {
+		// The index of the last source file setting the interval, indexed by policy name
+		lastSourceFiles := map[string]int{}
+		for srcFileIndex := range sourceFiles {
+			if interval.value(&sourceFiles[srcFileIndex].EvaluationInterval) != pgtformat.UnsetStringValue {
+				lastSourceFiles[sourceFiles[srcFileIndex].PolicyName] = srcFileIndex
+			}
+		}
+		for srcFileIndex := range sourceFiles {
+			sourceFile := &sourceFiles[srcFileIndex]
+			value := interval.value(&sourceFile.EvaluationInterval)
+			last := &sourceFiles[lastSourceFiles[sourceFile.PolicyName]]
+			if value == pgtformat.UnsetStringValue || value == interval.value(&last.EvaluationInterval) {
+				continue
+			}
+			options.report.WarnfAt(sourceFilePosition(options, inputFile, sourceFile, "evaluationInterval."+interval.name),
+				"PolicyGenTemplate %s: source file %s sets evaluationInterval.%s to %q, it is dropped, the policy %s-%s uses the %q of source file %s",
+				policyGenTemp.Metadata.Name, sourceFile.FileName, interval.name, value, policyGenTemp.Metadata.Name, sourceFile.PolicyName,
+				interval.value(&last.EvaluationInterval), last.FileName)
+		}
+	}
+}
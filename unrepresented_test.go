@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/test-network-function/pgt2acm/packages/pgtformat"
+	"github.com/test-network-function/pgt2acm/packages/report"
+	"gopkg.in/yaml.v3"
+)
+
+// A PGT with fields dropped by the conversion
+const unrepresentedFieldsPGT = `apiVersion: ran.openshift.io/v1
+kind: PolicyGenTemplate
+metadata:
+  name: pgt
+  namespace: ztp-common
+  finalizers: [keep]
+spec:
+  remediationaction: enforce
+  evaluationInterval:
+    compliant: 5m
+    interval: 1m
+  sourceFiles:
+    - fileName: ConfigMapGeneric.yaml
+      policyName: config-policy
+      evaluationInterval:
+        compliant: 1m
+      spec:
+        unknown: kept in the patch
+    - fileName: PtpConfigSlave.yaml
+      policyName: config-policy
+      evaluationInterval:
+        compliant: 2m
+      severity: high
+    - fileName: ClusterLogNS.yaml
+`
+
+func TestReportUnrepresentedFields(t *testing.T) {
+	document := yaml.Node{}
+	err := yaml.Unmarshal([]byte(unrepresentedFieldsPGT), &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policyGenTemp := pgtformat.PolicyGenTemplate{}
+	err = document.Decode(&policyGenTemp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := &conversionOptions{report: &report.Report{},
+		pgtNodes: map[*pgtformat.PolicyGenTemplate]*yaml.Node{}, sourceFileNodes: map[*pgtformat.SourceFile]*yaml.Node{}}
+	registerPGTNodes(options, &document, &policyGenTemp)
+
+	reportUnrepresentedFields(options, "pgt.yaml", &policyGenTemp)
+
+	// The source file spec is a free-form patch, its fields are not reported
+	want := []string{
+		`pgt.yaml:6:3: warning: PolicyGenTemplate pgt: metadata.finalizers is not a PolicyGenTemplate field, it is dropped`,
+		`pgt.yaml:8:3: warning: PolicyGenTemplate pgt: spec.remediationaction is not a PolicyGenTemplate field, it is dropped`,
+		`pgt.yaml:11:5: warning: PolicyGenTemplate pgt: spec.evaluationInterval.interval is not a PolicyGenTemplate field, it is dropped`,
+		`pgt.yaml:23:7: warning: PolicyGenTemplate pgt: spec.sourceFiles[1].severity is not a PolicyGenTemplate field, it is dropped`,
+		`pgt.yaml:24:7: warning: PolicyGenTemplate pgt: source file ClusterLogNS.yaml has no policyName, it is converted to the policy pgt-`,
+		`pgt.yaml:16:9: warning: PolicyGenTemplate pgt: source file ConfigMapGeneric.yaml sets evaluationInterval.compliant to "1m", it is dropped, ` +
+			`the policy pgt-config-policy uses the "2m" of source file PtpConfigSlave.yaml`,
+	}
+	if len(options.report.Entries) != len(want) {
+		t.Fatalf("report entries = %v, want %d entries", options.report.Entries, len(want))
+	}
+	for index := range want {
+		if entry := options.report.Entries[index].String(); entry != want[index] {
+			t.Errorf("report entry %d = %s, want %s", index, entry, want[index])
+		}
+	}
+}